@@ -7,6 +7,7 @@ require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/go-chi/chi v1.5.4
 	github.com/go-openapi/spec v0.20.9
+	github.com/gorilla/websocket v1.5.0
 	github.com/grsmv/inflect v0.0.0-20140723132642-a28d3de3b3ad
 	github.com/hjson/hjson-go/v4 v4.4.0
 	github.com/kataras/iris/v12 v12.2.0-beta1
@@ -3,11 +3,13 @@ package gengen
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"go/ast"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/go-openapi/spec"
@@ -21,6 +23,9 @@ type ClientGenerator struct {
 
 	config            ClientConfig
 	convertParamTypes string
+	errorMapping      string
+	securitySchemes   map[string]*spec.SecurityScheme
+	outputDeepObject  bool
 }
 
 func (cmd *ClientGenerator) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -33,6 +38,8 @@ func (cmd *ClientGenerator) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	fs.StringVar(&cmd.config.ContextClassName, "context", "context.Context", "")
 	fs.StringVar(&cmd.config.newRequest, "new-request", "resty.NewRequest({{.proxy}},{{.url}})", "")
 	fs.StringVar(&cmd.config.releaseRequest, "free-request", "resty.ReleaseRequest({{.proxy}},{{.request}})", "")
+	fs.StringVar(&cmd.config.dialWebsocket, "dial-websocket", "", "建立 websocket 连接的函数, 如 DialWebsocket({{.ctx}},{{.proxy}},{{.request}}), 有 websocket 方法时必须指定")
+	fs.StringVar(&cmd.config.expectedStatus, "expected-status", "ExpectedStatus({{.codes}})", "设置哪些状态码为成功, 为空时不设置")
 
 	fs.StringVar(&cmd.config.ConvertNS, "convert_ns", "", "")
 	fs.StringVar(&cmd.config.TimeFormat, "timeFormat", "client.Proxy.TimeFormat", "")
@@ -42,6 +49,9 @@ func (cmd *ClientGenerator) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	fs.StringVar(&cmd.config.WrapperData, "wrapper-data", "Data", "")
 	fs.StringVar(&cmd.config.WrapperError, "wrapper-error", "Error", "")
 	fs.StringVar(&cmd.convertParamTypes, "convert_param_types", os.Getenv("GOGEN_CONVERT_PARAM_TYPES"), "自定义的转换类型，多个类型时以逗号分隔")
+	fs.StringVar(&cmd.config.ProblemType, "problem-type", os.Getenv("GOGEN_PROBLEM_TYPE"), "服务端以 application/problem+json 返回错误时, 将错误解码为这个类型, 如 Problem")
+	fs.BoolVar(&cmd.outputDeepObject, "output-deep-object", false, "生成 DeepObjectToValues 函数")
+	fs.StringVar(&cmd.errorMapping, "error-mapping", os.Getenv("GOGEN_ERROR_MAPPING"), "状态码到 error 的映射，如 ErrNotFound=404,*ValidationError=422")
 
 	return fs
 }
@@ -53,6 +63,12 @@ func (cmd *ClientGenerator) Run(args []string) error {
 
 	convertParamTypes = strings.Split(cmd.convertParamTypes, ",")
 
+	mappings, err := ParseErrorMappings(cmd.errorMapping)
+	if err != nil {
+		return err
+	}
+	cmd.config.ErrorMappings = mappings
+
 	swaggerParser := swag.New()
 	swaggerParser.GoGenEnabled = true
 	swaggerParser.ParseVendor = true
@@ -65,15 +81,10 @@ func (cmd *ClientGenerator) Run(args []string) error {
 		if err != nil {
 			return err
 		}
-		err = swaggerParser.Packages().CollectAstFile(file.Package.ImportPath, file.Filename, file.AstFile)
-		if err != nil {
-			return errors.New("collect astFile: " + err.Error())
-		}
 		files = append(files, file)
 	}
-	_, err := swaggerParser.Packages().ParseTypes()
-	if err != nil {
-		return errors.New("parse types: " + err.Error())
+	if err := collectAstFiles(swaggerParser, files); err != nil {
+		return err
 	}
 
 	for idx, file := range files {
@@ -90,7 +101,12 @@ func (cmd *ClientGenerator) Run(args []string) error {
 			return err
 		}
 
-		for _, ts := range file.TypeList {
+		typeList, err := instantiateTypes(file.TypeList)
+		if err != nil {
+			return err
+		}
+		cmd.securitySchemes = readSecuritySchemes(file)
+		for _, ts := range typeList {
 			if ts.Interface == nil && ts.Struct == nil {
 				continue
 			}
@@ -113,15 +129,7 @@ func (cmd *ClientGenerator) Run(args []string) error {
 }
 
 func (cmd *ClientGenerator) genHeader(out io.Writer, swaggerParser *swag.Parser, file *astutil.File) error {
-	if cmd.buildTag != "" {
-		io.WriteString(out, "//go:build ")
-		io.WriteString(out, cmd.buildTag)
-		io.WriteString(out, "\r\n")
-		io.WriteString(out, "// +build ")
-		io.WriteString(out, cmd.buildTag)
-		io.WriteString(out, "\r\n")
-		io.WriteString(out, "\r\n")
-	}
+	renderBuildTag(out, cmd.buildTag, file)
 	io.WriteString(out, "// Please don't edit this file!\r\npackage ")
 	io.WriteString(out, file.Pkg.Name)
 	io.WriteString(out, "\r\n\r\nimport (")
@@ -179,6 +187,11 @@ func (cmd *ClientGenerator) genHeader(out io.Writer, swaggerParser *swag.Parser,
 
 	io.WriteString(out, "\r\n\t")
 	io.WriteString(out, `"github.com/runner-mei/resty"`)
+	// websocket 用 gorilla/websocket, 只在有 @x-gogen-websocket 时才导入
+	if !isFileImport("websocket") && hasWebsocketMethod(file) {
+		io.WriteString(out, "\r\n\t")
+		io.WriteString(out, `"github.com/gorilla/websocket"`)
+	}
 
 	if s := os.Getenv("GOGEN_IMPORTS"); s != "" {
 		for _, pa := range strings.Split(s, ",") {
@@ -208,6 +221,12 @@ func (cmd *ClientGenerator) genHeader(out io.Writer, swaggerParser *swag.Parser,
 	// 	io.WriteString(out, "\""+pa+"\"")
 	// }
 	io.WriteString(out, "\r\n)\r\n")
+
+	if cmd.outputDeepObject {
+		io.WriteString(out, "\r\n")
+		io.WriteString(out, deepObjectToValuesTxt)
+		io.WriteString(out, "\r\n")
+	}
 	return nil
 }
 
@@ -258,7 +277,8 @@ func (cmd *ClientGenerator) genInterfaceImpl(out io.Writer, swaggerParser *swag.
 			}
 		}
 	}
-	if ignore {
+	// 泛型的接口只能被嵌入到其它接口中或用 @gogen.instantiate 实例化
+	if ignore || ts.Node.TypeParams != nil {
 		io.WriteString(out, "\r\n// "+ts.Name+" is skipped")
 		return nil
 	}
@@ -299,6 +319,7 @@ func (cmd *ClientGenerator) genInterfaceImpl(out io.Writer, swaggerParser *swag.
 	io.WriteString(out, "\r\n\r\ntype ")
 	io.WriteString(out, className+" struct {")
 	io.WriteString(out, "\r\n\t"+cmd.config.RestyField+" "+cmd.config.RestyName)
+	genClientCredentialFields(out, securityNames(methods), cmd.securitySchemes)
 
 	if optionalRoutePrefix != "" {
 		io.WriteString(out, "\r\n  NoRoutePrefix bool")
@@ -386,6 +407,9 @@ func (cmd *ClientGenerator) genInterfaceMethodSignature(out io.Writer, recvClass
 		if param.Type().IsContextType() {
 			continue
 		}
+		if _, ok := method.ContextValueKey(param.Name); ok {
+			continue
+		}
 
 		io.WriteString(out, ", "+formatParamName(param.Name)+" ")
 		if param.IsVariadic {
@@ -395,11 +419,16 @@ func (cmd *ClientGenerator) genInterfaceMethodSignature(out io.Writer, recvClass
 	}
 	io.WriteString(out, ") (")
 
-	for _, result := range method.Method.Results.List {
+	for idx, result := range method.Method.Results.List {
 		if result.Type().IsErrorType() {
 			continue
 		}
 
+		if idx == 0 && isStreamResult(method) {
+			// 客户端总是返回 io.ReadCloser, 调用者需要关闭它
+			io.WriteString(out, "io.ReadCloser,")
+			continue
+		}
 		io.WriteString(out, result.Type().ToLiteral())
 		io.WriteString(out, ",")
 	}
@@ -465,10 +494,37 @@ func (cmd *ClientGenerator) genInterfaceMethod(out io.Writer, recvClassName stri
 		return err
 	}
 
-	if err := cmd.genInterfaceMethodReturnVars(out, recvClassName, method); err != nil {
+	if headers := responseHeaderResults(method); len(headers) > 0 {
+		for _, result := range method.Method.Results.List {
+			if result.Type().IsErrorType() {
+				continue
+			}
+			io.WriteString(out, "\r\n\tvar "+result.Name+" "+result.Type().ToLiteral())
+		}
+		io.WriteString(out, "\r\n")
+	} else if method.IsWebsocket() {
+	} else if _, ok := seqElemType(method); ok {
+	} else if yieldIdx, _ := yieldParam(method); yieldIdx >= 0 {
+	} else if elemType, ok := eventStreamElemType(method); ok {
+		io.WriteString(out, "\r\n\t"+getResultName(method)+" := make(chan "+elemType+")")
+		io.WriteString(out, "\r\n")
+	} else if isStreamResult(method) {
+		io.WriteString(out, "\r\n\tvar "+getResultName(method)+" io.ReadCloser")
+		if len(method.Method.Results.List) > 2 {
+			io.WriteString(out, "\r\n\tvar filename string")
+		}
+		io.WriteString(out, "\r\n")
+	} else if err := cmd.genInterfaceMethodReturnVars(out, recvClassName, method); err != nil {
 		return err
 	}
 
+	if elemType, ok := seqElemType(method); ok {
+		// 每次遍历时都创建一个新的请求, 并在遍历结束时释放它
+		io.WriteString(out, "\r\n\treturn func(yield func("+elemType+", error) bool) {")
+	}
+	if needTextErr(method) {
+		io.WriteString(out, "\r\n\tvar textErr error")
+	}
 	io.WriteString(out, "\r\n\trequest := ")
 	io.WriteString(out, cmd.config.NewRequest("client."+cmd.config.RestyField, cmd.config.GetPath(optionalRoutePrefix, method)))
 
@@ -479,11 +535,22 @@ func (cmd *ClientGenerator) genInterfaceMethod(out io.Writer, recvClassName stri
 		if param.Type().IsContextType() {
 			continue
 		}
+		if _, ok := method.ContextValueKey(param.Name); ok {
+			continue
+		}
 
 		if typeStr := param.Type().ToLiteral(); typeStr == "*http.Request" ||
 			typeStr == "http.ResponseWriter" {
 			continue
 		}
+		if yieldIdx, _ := yieldParam(method); yieldIdx >= 0 &&
+			method.Method.Params.List[yieldIdx].Name == param.Name {
+			continue
+		}
+		if inIdx, _ := websocketInParam(method); inIdx >= 0 && method.IsWebsocket() &&
+			method.Method.Params.List[inIdx].Name == param.Name {
+			continue
+		}
 
 		switch param.Type().ToLiteral() {
 		case "map[string]string":
@@ -619,6 +686,18 @@ func (cmd *ClientGenerator) genInterfaceMethod(out io.Writer, recvClassName stri
 		return errors.New("'" + param.Name + "' is unsupported type - '" + param.Type().ToLiteral() + "'")
 	}
 
+	cmd.genClientCredentials(out, method, cmd.securitySchemes, &needAssignment)
+
+	if expected := cmd.config.ExpectedStatus(method); expected != "" && !method.IsWebsocket() {
+		if needAssignment {
+			io.WriteString(out, "\r\nrequest = request.")
+		} else {
+			io.WriteString(out, ".\r\n")
+		}
+		needAssignment = false
+		io.WriteString(out, expected)
+	}
+
 	if len(inBody) > 0 {
 		if needAssignment {
 			io.WriteString(out, "\r\nrequest = request.")
@@ -639,6 +718,25 @@ func (cmd *ClientGenerator) genInterfaceMethod(out io.Writer, recvClassName stri
 		}
 	}
 
+	if method.IsWebsocket() {
+		return cmd.genInterfaceMethodWebsocket(out, method)
+	}
+	if headers := responseHeaderResults(method); len(headers) > 0 {
+		return cmd.genInterfaceMethodHeaderResults(out, method, headers, needAssignment)
+	}
+	if elemType, ok := seqElemType(method); ok {
+		return cmd.genInterfaceMethodSeqResult(out, method, elemType)
+	}
+	if yieldIdx, elemType := yieldParam(method); yieldIdx >= 0 {
+		return cmd.genInterfaceMethodYieldResult(out, method, elemType, method.Method.Params.List[yieldIdx].Name, needAssignment)
+	}
+	if elemType, ok := eventStreamElemType(method); ok {
+		return cmd.genInterfaceMethodEventStreamResult(out, method, elemType, needAssignment)
+	}
+	if isStreamResult(method) {
+		return cmd.genInterfaceMethodStreamResult(out, method, needAssignment)
+	}
+
 	resultCount := getResultCount(method)
 	if resultCount > 0 {
 		if needAssignment {
@@ -650,6 +748,20 @@ func (cmd *ClientGenerator) genInterfaceMethod(out io.Writer, recvClassName stri
 		resultName := getResultName(method)
 		if cmd.config.HasWrapper {
 			io.WriteString(out, "Result(&"+resultName+"Wrap)")
+		} else if typeStr, ok := rawResultType(method); ok {
+			// 服务端直接输出了字符串或 []byte, 不能按 json 解码
+			io.WriteString(out, "Result(func(resp *http.Response) error {")
+			io.WriteString(out, "\r\n\t\tbs, err := io.ReadAll(resp.Body)")
+			io.WriteString(out, "\r\n\t\tif err != nil {")
+			io.WriteString(out, "\r\n\t\t\treturn err")
+			io.WriteString(out, "\r\n\t\t}")
+			if typeStr == "string" {
+				io.WriteString(out, "\r\n\t\t"+resultName+" = string(bs)")
+			} else {
+				io.WriteString(out, "\r\n\t\t"+resultName+" = bs")
+			}
+			io.WriteString(out, "\r\n\t\treturn nil")
+			io.WriteString(out, "\r\n\t})")
 		} else {
 			io.WriteString(out, "Result(&"+resultName+")")
 		}
@@ -657,17 +769,43 @@ func (cmd *ClientGenerator) genInterfaceMethod(out io.Writer, recvClassName stri
 	return cmd.genInterfaceMethodInvokeAndReturn(out, recvClassName, method)
 }
 
+// rawResultType 当只有一个 string 或 []byte 返回值, 并且服务端默认以 application/octet-stream 或
+// text/plain 直接输出它时返回它的类型
+func rawResultType(method *Method) (string, bool) {
+	results := method.Method.Results.List
+	if len(results) != 2 || !results[1].Type().IsErrorType() {
+		return "", false
+	}
+	typeStr := results[0].Type().ToLiteral()
+	if typeStr != "string" && typeStr != "[]byte" {
+		return "", false
+	}
+	produces := method.Operation.Produces
+	if len(produces) == 0 ||
+		(produces[0] != "application/octet-stream" && produces[0] != "text/plain") {
+		return "", false
+	}
+	return typeStr, true
+}
+
 func (cmd *ClientGenerator) genInterfaceMethodInvokeAndReturn(out io.Writer, recvClassName string, method *Method) error {
 	resultCount := getResultCount(method)
 
-	if resultCount == 0 /* && !cmd.config.HasWrapper */ {
+	if cmd.config.ProblemType != "" || len(errorMappings(method, cmd.config.ErrorMappings)) > 0 || needTextErr(method) {
+		cmd.genInvokeRequest(out, method)
+		if resultCount == 0 {
+			io.WriteString(out, "\r\n\treturn err")
+			io.WriteString(out, "\r\n}")
+			return nil
+		}
+	} else if resultCount == 0 /* && !cmd.config.HasWrapper */ {
 		io.WriteString(out, "\r\n")
 		io.WriteString(out, "\r\ndefer "+cmd.config.ReleaseRequest("client."+cmd.config.RestyField, "request"))
 		io.WriteString(out, "\r\nreturn ")
+		io.WriteString(out, "request."+cmd.config.RouteFunc(method)+"(ctx)")
 	} else {
-		io.WriteString(out, "\r\n\r\nerr := ")
+		cmd.genInvokeRequest(out, method)
 	}
-	io.WriteString(out, "request."+cmd.config.RouteFunc(method)+"(ctx)")
 
 	resultName := getResultName(method)
 	if resultCount == 0 {
@@ -684,7 +822,6 @@ func (cmd *ClientGenerator) genInterfaceMethodInvokeAndReturn(out io.Writer, rec
 		// 	io.WriteString(out, "\r\n\treturn nil")
 		// }
 	} else if resultCount == 1 {
-		io.WriteString(out, "\r\n\t"+cmd.config.ReleaseRequest("client."+cmd.config.RestyField, "request"))
 
 		zeroValueStr := zeroValueLiteral(method.Method.Results.List[0].Type())
 		if cmd.config.HasWrapper {
@@ -706,8 +843,6 @@ func (cmd *ClientGenerator) genInterfaceMethodInvokeAndReturn(out io.Writer, rec
 			}
 		}
 	} else {
-		io.WriteString(out, "\r\n\t"+cmd.config.ReleaseRequest("client."+cmd.config.RestyField, "request"))
-
 		io.WriteString(out, "\r\n")
 
 		var sb strings.Builder
@@ -756,6 +891,424 @@ func (cmd *ClientGenerator) genInterfaceMethodInvokeAndReturn(out io.Writer, rec
 	return nil
 }
 
+// genInvokeRequest 发出请求并释放它, 有 @x-gogen-error 时将错误的状态码转换回对应的 error,
+// 指定了 problem-type 时将其它的错误解码为 *<problem-type>, 错误类型的值用
+// <convert_ns>DecodeError(err, target) 从 err 中解码, 这个函数需要你自已定义, 参数的 MarshalText
+// 出错时不发出请求, 直接返回这个错误
+func (cmd *ClientGenerator) genInvokeRequest(out io.Writer, method *Method) {
+	if needTextErr(method) {
+		io.WriteString(out, "\r\n\r\nerr := textErr")
+		io.WriteString(out, "\r\n\tif err == nil {")
+		io.WriteString(out, "\r\n\t\terr = request."+cmd.config.RouteFunc(method)+"(ctx)")
+		io.WriteString(out, "\r\n\t}")
+	} else {
+		io.WriteString(out, "\r\n\r\nerr := request."+cmd.config.RouteFunc(method)+"(ctx)")
+	}
+	io.WriteString(out, "\r\n\t"+cmd.config.ReleaseRequest("client."+cmd.config.RestyField, "request"))
+
+	mappings := errorMappings(method, cmd.config.ErrorMappings)
+	if len(mappings) == 0 {
+		if cmd.config.ProblemType != "" {
+			// 与有 @x-gogen-error 时一样, 只有带状态码的错误才解码
+			io.WriteString(out, "\r\n\tif err != nil {")
+			io.WriteString(out, "\r\n\t\tvar coder interface{ HTTPCode() int }")
+			io.WriteString(out, "\r\n\t\tif errors.As(err, &coder) {")
+			cmd.genDecodeError(out, "\t\t\t", "*"+cmd.config.ProblemType)
+			io.WriteString(out, "\r\n\t\t}")
+			io.WriteString(out, "\r\n\t}")
+		}
+		return
+	}
+	io.WriteString(out, "\r\n\tif err != nil {")
+	io.WriteString(out, "\r\n\t\tvar coder interface{ HTTPCode() int }")
+	io.WriteString(out, "\r\n\t\tif errors.As(err, &coder) {")
+	io.WriteString(out, "\r\n\t\t\tswitch coder.HTTPCode() {")
+	seen := map[int]bool{}
+	for _, mapping := range mappings {
+		if seen[mapping.StatusCode] {
+			continue
+		}
+		seen[mapping.StatusCode] = true
+
+		io.WriteString(out, "\r\n\t\t\tcase "+statusCodeLiteral(mapping.StatusCode)+":")
+		if mapping.IsType() {
+			cmd.genDecodeError(out, "\t\t\t\t", mapping.Error)
+		} else {
+			// 用 %w 包装, 这样 errors.Is 仍然可以匹配, 同时保留服务端返回的错误信息
+			io.WriteString(out, "\r\n\t\t\t\terr = fmt.Errorf(\"%w: %s\", "+mapping.Error+", err)")
+		}
+	}
+	if cmd.config.ProblemType != "" {
+		io.WriteString(out, "\r\n\t\t\tdefault:")
+		cmd.genDecodeError(out, "\t\t\t\t", "*"+cmd.config.ProblemType)
+	}
+	io.WriteString(out, "\r\n\t\t\t}")
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t}")
+}
+
+func (cmd *ClientGenerator) genDecodeError(out io.Writer, indent, errType string) {
+	io.WriteString(out, "\r\n"+indent+"target := new("+strings.TrimPrefix(errType, "*")+")")
+	io.WriteString(out, "\r\n"+indent+"if "+cmd.config.ConvertNS+"DecodeError(err, target) {")
+	io.WriteString(out, "\r\n"+indent+"\terr = target")
+	io.WriteString(out, "\r\n"+indent+"}")
+}
+
+// genInterfaceMethodStreamResult 直接返回响应的 Body 而不是解码 json, 有文件名时从
+// Content-Disposition 头中读取
+func (cmd *ClientGenerator) genInterfaceMethodStreamResult(out io.Writer, method *Method, needAssignment bool) error {
+	if needAssignment {
+		io.WriteString(out, "\r\nrequest = request.")
+	} else {
+		io.WriteString(out, ".\r\n")
+	}
+
+	resultName := getResultName(method)
+	hasFilename := len(method.Method.Results.List) > 2
+
+	io.WriteString(out, "Result(func(resp *http.Response) error {")
+	io.WriteString(out, "\r\n\t\t"+resultName+" = resp.Body")
+	if hasFilename {
+		io.WriteString(out, "\r\n\t\tif _, params, err := mime.ParseMediaType(resp.Header.Get(\"Content-Disposition\")); err == nil {")
+		io.WriteString(out, "\r\n\t\t\tfilename = params[\"filename\"]")
+		io.WriteString(out, "\r\n\t\t}")
+	}
+	io.WriteString(out, "\r\n\t\treturn nil")
+	io.WriteString(out, "\r\n\t})")
+
+	cmd.genInvokeRequest(out, method)
+	if hasFilename {
+		io.WriteString(out, "\r\n\treturn "+resultName+", filename, err")
+	} else {
+		io.WriteString(out, "\r\n\treturn "+resultName+", err")
+	}
+	io.WriteString(out, "\r\n}")
+	return nil
+}
+
+// genInterfaceMethodHeaderResults 从响应头中读取绑定到 @Header 的返回参数, 从 Set-Cookie 中读取 *http.Cookie
+// 类型的返回参数, 其它的返回参数从 body 中解码
+func (cmd *ClientGenerator) genInterfaceMethodHeaderResults(out io.Writer, method *Method, headers map[int]string, needAssignment bool) error {
+	if needAssignment {
+		io.WriteString(out, "\r\nrequest = request.")
+	} else {
+		io.WriteString(out, ".\r\n")
+	}
+
+	io.WriteString(out, "Result(func(resp *http.Response) error {")
+
+	var bodyResults []astutil.Result
+	for idx, result := range method.Method.Results.List {
+		if result.Type().IsErrorType() {
+			continue
+		}
+		name, ok := headers[idx]
+		if !ok {
+			bodyResults = append(bodyResults, result)
+			continue
+		}
+
+		typ := result.Type()
+		switch {
+		case isCookieType(typ):
+			// 按返回参数的名称来查找 cookie
+			value := "cookie"
+			if !typ.IsPtrType() {
+				value = "*" + value
+			}
+			io.WriteString(out, "\r\n\t\tfor _, cookie := range resp.Cookies() {")
+			io.WriteString(out, "\r\n\t\t\tif cookie.Name == \""+result.Name+"\" {")
+			io.WriteString(out, "\r\n\t\t\t\t"+result.Name+" = "+value)
+			io.WriteString(out, "\r\n\t\t\t\tbreak")
+			io.WriteString(out, "\r\n\t\t\t}")
+			io.WriteString(out, "\r\n\t\t}")
+		case typ.ToLiteral() == "string":
+			io.WriteString(out, "\r\n\t\t"+result.Name+" = resp.Header.Get(\""+name+"\")")
+		default:
+			// 用与服务端读取参数时相同的转换函数
+			convertFmt, needCast, _, err := selectConvert(cmd.config.ConvertNS, false, "string", typ.ToLiteral())
+			if err != nil {
+				if isTextUnmarshaler(typ) {
+					convertFmt, needCast, err = selectTextConvert(false, typ.ToLiteral()), false, nil
+				} else if underlying := typ.GetUnderlyingType(); underlying.IsValid() {
+					convertFmt, _, _, err = selectConvert(cmd.config.ConvertNS, false, "string", underlying.ToLiteral())
+					needCast = true
+				}
+				if err != nil {
+					return errors.New(method.Method.Clazz.File.PostionFor(method.Method.Node.Pos()).String() +
+						": result '" + result.Name + "' of '" + method.FullName() + "' hasnot convert function: " + err.Error())
+				}
+			}
+			value := "value"
+			if needCast {
+				value = typ.ToLiteral() + "(value)"
+			}
+			io.WriteString(out, "\r\n\t\tif s := resp.Header.Get(\""+name+"\"); s != \"\" {")
+			io.WriteString(out, "\r\n\t\t\tvalue, err := "+fmt.Sprintf(convertFmt, "s"))
+			io.WriteString(out, "\r\n\t\t\tif err != nil {")
+			io.WriteString(out, "\r\n\t\t\t\treturn err")
+			io.WriteString(out, "\r\n\t\t\t}")
+			io.WriteString(out, "\r\n\t\t\t"+result.Name+" = "+value)
+			io.WriteString(out, "\r\n\t\t}")
+		}
+	}
+
+	switch len(bodyResults) {
+	case 0:
+		io.WriteString(out, "\r\n\t\treturn nil")
+	case 1:
+		io.WriteString(out, "\r\n\t\treturn json.NewDecoder(resp.Body).Decode(&"+bodyResults[0].Name+")")
+	default:
+		io.WriteString(out, "\r\n\t\treturn json.NewDecoder(resp.Body).Decode(&struct {")
+		for _, result := range bodyResults {
+			io.WriteString(out, "\r\n\t\t\tE"+result.Name+" *"+result.Type().ToLiteral()+" `json:\""+Underscore(result.Name)+"\"`")
+		}
+		io.WriteString(out, "\r\n\t\t}{")
+		for _, result := range bodyResults {
+			io.WriteString(out, "&"+result.Name+", ")
+		}
+		io.WriteString(out, "})")
+	}
+	io.WriteString(out, "\r\n\t})")
+
+	cmd.genInvokeRequest(out, method)
+	io.WriteString(out, "\r\n\treturn ")
+	for _, result := range method.Method.Results.List {
+		if result.Type().IsErrorType() {
+			continue
+		}
+		io.WriteString(out, result.Name+", ")
+	}
+	io.WriteString(out, "err")
+	io.WriteString(out, "\r\n}")
+	return nil
+}
+
+// genInterfaceMethodWebsocket 连接 websocket, 将 in 参数中的值编码为 json 发送给服务端,
+// 并把收到的 json 消息解码后发送到返回的 channel 中, 连接断开或 ctx 被取消时关闭 channel
+func (cmd *ClientGenerator) genInterfaceMethodWebsocket(out io.Writer, method *Method) error {
+	elemType, ok := eventStreamElemType(method)
+	if !ok {
+		return errors.New("websocket method '" + method.FullName() + "' must return (<-chan T, error)")
+	}
+	if cmd.config.dialWebsocket == "" {
+		return errors.New("websocket method '" + method.FullName() + "' requires the dial-websocket flag")
+	}
+	inIdx, _ := websocketInParam(method)
+	resultName := getResultName(method)
+
+	if needTextErr(method) {
+		io.WriteString(out, "\r\n\r\nif textErr != nil {")
+		io.WriteString(out, "\r\n\t"+cmd.config.ReleaseRequest("client."+cmd.config.RestyField, "request"))
+		io.WriteString(out, "\r\n\treturn nil, textErr")
+		io.WriteString(out, "\r\n}")
+	}
+	io.WriteString(out, "\r\n\r\nconn, err := "+cmd.config.DialWebsocket("ctx", "client."+cmd.config.RestyField, "request"))
+	io.WriteString(out, "\r\n\t"+cmd.config.ReleaseRequest("client."+cmd.config.RestyField, "request"))
+	io.WriteString(out, "\r\n\tif err != nil {")
+	io.WriteString(out, "\r\n\t\treturn nil, err")
+	io.WriteString(out, "\r\n\t}")
+	io.WriteString(out, "\r\n")
+	io.WriteString(out, "\r\n\t"+resultName+" := make(chan "+elemType+")")
+	io.WriteString(out, "\r\n\tdone := make(chan struct{})")
+	io.WriteString(out, "\r\n\tgo func() {")
+	io.WriteString(out, "\r\n\t\tfor {")
+	io.WriteString(out, "\r\n\t\t\tselect {")
+	io.WriteString(out, "\r\n\t\t\tcase <-done:")
+	io.WriteString(out, "\r\n\t\t\t\treturn")
+	io.WriteString(out, "\r\n\t\t\tcase <-ctx.Done():")
+	io.WriteString(out, "\r\n\t\t\t\tconn.Close()")
+	io.WriteString(out, "\r\n\t\t\t\treturn")
+	if inIdx >= 0 {
+		in := formatParamName(method.Method.Params.List[inIdx].Name)
+		io.WriteString(out, "\r\n\t\t\tcase msg, ok := <-"+in+":")
+		io.WriteString(out, "\r\n\t\t\t\tif !ok {")
+		io.WriteString(out, "\r\n\t\t\t\t\tconn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, \"\"))")
+		io.WriteString(out, "\r\n\t\t\t\t\treturn")
+		io.WriteString(out, "\r\n\t\t\t\t}")
+		io.WriteString(out, "\r\n\t\t\t\tif err := conn.WriteJSON(msg); err != nil {")
+		io.WriteString(out, "\r\n\t\t\t\t\treturn")
+		io.WriteString(out, "\r\n\t\t\t\t}")
+	}
+	io.WriteString(out, "\r\n\t\t\t}")
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t}()")
+	io.WriteString(out, "\r\n\tgo func() {")
+	io.WriteString(out, "\r\n\t\tdefer close("+resultName+")")
+	io.WriteString(out, "\r\n\t\tdefer close(done)")
+	io.WriteString(out, "\r\n\t\tdefer conn.Close()")
+	io.WriteString(out, "\r\n")
+	io.WriteString(out, "\r\n\t\tfor {")
+	io.WriteString(out, "\r\n\t\t\tvar reply "+elemType)
+	io.WriteString(out, "\r\n\t\t\tif err := conn.ReadJSON(&reply); err != nil {")
+	io.WriteString(out, "\r\n\t\t\t\treturn")
+	io.WriteString(out, "\r\n\t\t\t}")
+	io.WriteString(out, "\r\n\t\t\tselect {")
+	io.WriteString(out, "\r\n\t\t\tcase "+resultName+" <- reply:")
+	io.WriteString(out, "\r\n\t\t\tcase <-ctx.Done():")
+	io.WriteString(out, "\r\n\t\t\t\treturn")
+	io.WriteString(out, "\r\n\t\t\t}")
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t}()")
+	io.WriteString(out, "\r\n\treturn "+resultName+", nil")
+	io.WriteString(out, "\r\n}")
+	return nil
+}
+
+// genNDJSONDecode 输出按行解码 ndjson 的函数, 读完后检查 X-Stream-Error 这个 Trailer
+func (cmd *ClientGenerator) genNDJSONDecode(out io.Writer, elemType, yield string) {
+	io.WriteString(out, "func(resp *http.Response) error {")
+	io.WriteString(out, "\r\n\t\tdefer resp.Body.Close()")
+	io.WriteString(out, "\r\n")
+	io.WriteString(out, "\r\n\t\tdecoder := json.NewDecoder(resp.Body)")
+	io.WriteString(out, "\r\n\t\tfor {")
+	io.WriteString(out, "\r\n\t\t\tvar item "+elemType)
+	io.WriteString(out, "\r\n\t\t\tif err := decoder.Decode(&item); err != nil {")
+	io.WriteString(out, "\r\n\t\t\t\tif err != io.EOF {")
+	io.WriteString(out, "\r\n\t\t\t\t\treturn err")
+	io.WriteString(out, "\r\n\t\t\t\t}")
+	io.WriteString(out, "\r\n\t\t\t\tif s := resp.Trailer.Get(\"X-Stream-Error\"); s != \"\" {")
+	io.WriteString(out, "\r\n\t\t\t\t\treturn errors.New(s)")
+	io.WriteString(out, "\r\n\t\t\t\t}")
+	io.WriteString(out, "\r\n\t\t\t\treturn nil")
+	io.WriteString(out, "\r\n\t\t\t}")
+	io.WriteString(out, "\r\n\t\t\t"+yield)
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t}")
+}
+
+// genEventStreamDecode 输出按 SSE(text/event-stream) 读取响应的代码, 每个事件的 data 按 json 解码后
+// 执行 yield, 遇到 error 事件, 解码失败或读取出错时返回, returnErr 为 true 时返回这个错误
+func (cmd *ClientGenerator) genEventStreamDecode(out io.Writer, elemType, yield string, returnErr bool) {
+	fail := func(err string) string {
+		if returnErr {
+			return "return " + err
+		}
+		return "return"
+	}
+
+	io.WriteString(out, "\r\n\tvar event string")
+	io.WriteString(out, "\r\n\tvar data []byte")
+	io.WriteString(out, "\r\n\tscanner := bufio.NewScanner(resp.Body)")
+	io.WriteString(out, "\r\n\tfor scanner.Scan() {")
+	io.WriteString(out, "\r\n\t\tline := scanner.Bytes()")
+	io.WriteString(out, "\r\n\t\tif len(line) > 0 {")
+	io.WriteString(out, "\r\n\t\t\tif bytes.HasPrefix(line, []byte(\"event:\")) {")
+	io.WriteString(out, "\r\n\t\t\t\tevent = string(bytes.TrimSpace(line[6:]))")
+	io.WriteString(out, "\r\n\t\t\t} else if bytes.HasPrefix(line, []byte(\"data:\")) {")
+	io.WriteString(out, "\r\n\t\t\t\tif len(data) > 0 {")
+	io.WriteString(out, "\r\n\t\t\t\t\tdata = append(data, '\\n')")
+	io.WriteString(out, "\r\n\t\t\t\t}")
+	io.WriteString(out, "\r\n\t\t\t\tdata = append(data, bytes.TrimPrefix(line[5:], []byte(\" \"))...)")
+	io.WriteString(out, "\r\n\t\t\t}")
+	io.WriteString(out, "\r\n\t\t\tcontinue")
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t\tif event == \"error\" {")
+	if returnErr {
+		// data 是 json 编码的错误信息
+		io.WriteString(out, "\r\n\t\t\tvar msg string")
+		io.WriteString(out, "\r\n\t\t\tif err := json.Unmarshal(data, &msg); err != nil {")
+		io.WriteString(out, "\r\n\t\t\t\tmsg = string(data)")
+		io.WriteString(out, "\r\n\t\t\t}")
+	}
+	io.WriteString(out, "\r\n\t\t\t"+fail("errors.New(msg)"))
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t\tif len(data) == 0 {")
+	io.WriteString(out, "\r\n\t\t\tevent = \"\"")
+	io.WriteString(out, "\r\n\t\t\tcontinue")
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n")
+	io.WriteString(out, "\r\n\t\tvar item "+elemType)
+	io.WriteString(out, "\r\n\t\terr := json.Unmarshal(data, &item)")
+	io.WriteString(out, "\r\n\t\tevent, data = \"\", data[:0]")
+	io.WriteString(out, "\r\n\t\tif err != nil {")
+	io.WriteString(out, "\r\n\t\t\t"+fail("err"))
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t\t"+yield)
+	io.WriteString(out, "\r\n\t}")
+	// bufio.ErrTooLong 等读取时的错误
+	io.WriteString(out, "\r\n\tif err := scanner.Err(); err != nil {")
+	io.WriteString(out, "\r\n\t\t"+fail("err"))
+	io.WriteString(out, "\r\n\t}")
+}
+
+// genInterfaceMethodYieldResult 按 ndjson 读取响应, 对每个值调用 yield 参数
+func (cmd *ClientGenerator) genInterfaceMethodYieldResult(out io.Writer, method *Method, elemType, yieldName string, needAssignment bool) error {
+	if needAssignment {
+		io.WriteString(out, "\r\nrequest = request.")
+	} else {
+		io.WriteString(out, ".\r\n")
+	}
+
+	io.WriteString(out, "Result(")
+	cmd.genNDJSONDecode(out, elemType, "if err := "+yieldName+"(item); err != nil {\r\n\t\t\t\treturn err\r\n\t\t\t}")
+	io.WriteString(out, ")")
+
+	cmd.genInvokeRequest(out, method)
+	io.WriteString(out, "\r\n\treturn err")
+	io.WriteString(out, "\r\n}")
+	return nil
+}
+
+// genInterfaceMethodSeqResult 返回一个 iter.Seq2, 遍历它时才创建并发出请求, 按 ndjson 读取响应,
+// 请求失败时将错误作为最后一个值, 函数的开头和创建请求的代码已在 genInterfaceMethod 中输出
+func (cmd *ClientGenerator) genInterfaceMethodSeqResult(out io.Writer, method *Method, elemType string) error {
+	io.WriteString(out, "\r\n\r\n\tstopped := false")
+	io.WriteString(out, "\r\n\trequest = request.Result(")
+	if isEventStreamProduce(method) {
+		// error 事件和读取时的错误都作为最后一个值返回给调用者
+		io.WriteString(out, "func(resp *http.Response) error {")
+		io.WriteString(out, "\r\n\t\tdefer resp.Body.Close()")
+		io.WriteString(out, "\r\n")
+		cmd.genEventStreamDecode(out, elemType, "if !yield(item, nil) {\r\n\tstopped = true\r\n\treturn nil\r\n}", true)
+		io.WriteString(out, "\r\n\treturn nil")
+		io.WriteString(out, "\r\n\t}")
+	} else {
+		cmd.genNDJSONDecode(out, elemType, "if !yield(item, nil) {\r\n\t\t\t\tstopped = true\r\n\t\t\t\treturn nil\r\n\t\t\t}")
+	}
+	io.WriteString(out, ")")
+	cmd.genInvokeRequest(out, method)
+	io.WriteString(out, "\r\n\tif err != nil && !stopped {")
+	io.WriteString(out, "\r\n\t\tvar zero "+elemType)
+	io.WriteString(out, "\r\n\t\tyield(zero, err)")
+	io.WriteString(out, "\r\n\t}")
+	io.WriteString(out, "\r\n}, nil")
+	io.WriteString(out, "\r\n}")
+	return nil
+}
+
+// genInterfaceMethodEventStreamResult 按 SSE(text/event-stream) 读取响应, 将每个事件的 data
+// 按 json 解码后发送到返回的 channel 中, 响应结束或 ctx 被取消时关闭 channel
+func (cmd *ClientGenerator) genInterfaceMethodEventStreamResult(out io.Writer, method *Method, elemType string, needAssignment bool) error {
+	if needAssignment {
+		io.WriteString(out, "\r\nrequest = request.")
+	} else {
+		io.WriteString(out, ".\r\n")
+	}
+
+	resultName := getResultName(method)
+
+	io.WriteString(out, "Result(func(resp *http.Response) error {")
+	io.WriteString(out, "\r\n\t\tgo func() {")
+	io.WriteString(out, "\r\n\t\t\tdefer close("+resultName+")")
+	io.WriteString(out, "\r\n\t\t\tdefer resp.Body.Close()")
+	io.WriteString(out, "\r\n")
+	// channel 无法将错误传给调用者, 遇到 error 事件或读取出错时直接关闭它
+	cmd.genEventStreamDecode(out, elemType,
+		"select {\r\n\tcase "+resultName+" <- item:\r\n\tcase <-ctx.Done():\r\n\t\treturn\r\n\t}", false)
+	io.WriteString(out, "\r\n\t\t}()")
+	io.WriteString(out, "\r\n\t\treturn nil")
+	io.WriteString(out, "\r\n\t})")
+
+	cmd.genInvokeRequest(out, method)
+	io.WriteString(out, "\r\n\tif err != nil {")
+	io.WriteString(out, "\r\n\t\treturn nil, err")
+	io.WriteString(out, "\r\n\t}")
+	io.WriteString(out, "\r\n\treturn "+resultName+", nil")
+	io.WriteString(out, "\r\n}")
+	return nil
+}
+
 func (cmd *ClientGenerator) genInterfaceMethodStructParam(out io.Writer, method *Method, param *astutil.Param, webPrefix string, needAssignment *bool) error {
 	typ := param.Type()
 	if typ.IsPtrType() {
@@ -849,7 +1402,8 @@ func (cmd *ClientGenerator) genInterfaceMethodStructParam(out io.Writer, method
 		}
 		if fieldType.IsStructType() &&
 			!fieldType.IsSqlNullableType() &&
-			!isBultinType(fieldType.ToLiteral()) {
+			!isBultinType(fieldType.ToLiteral()) &&
+			!isTextUnmarshaler(fieldType) {
 			subparam := *param
 			subparam.ExprFile = fieldType.File
 			subparam.Expr = fieldType.Expr
@@ -877,6 +1431,33 @@ func (cmd *ClientGenerator) genInterfaceMethodStructParam(out io.Writer, method
 			continue
 		}
 
+		if isDeepObjectType(fields[idx].Type()) {
+			if *needAssignment {
+				io.WriteString(out, "\r\nrequest = request.")
+			} else {
+				io.WriteString(out, ".\r\n")
+			}
+			io.WriteString(out, "SetParams("+cmd.config.ConvertNS+"DeepObjectToValues(\""+webParamName+"\", "+goFieldName+"))")
+			*needAssignment = false
+			continue
+		}
+
+		if header, _ := getTagValue(&fields[idx], "header"); header != "" {
+			subparam := *param
+			subparam.Name = param.Name + "." + fields[idx].Name
+			subparam.ExprFile = fields[idx].Clazz.File
+			subparam.Expr = fields[idx].Expr
+
+			option := &spec.Parameter{}
+			option.Name = header
+			option.In = "header"
+			option.Required = !hasOmitEmpty
+			if err := cmd.genInterfaceMethodParam(out, method, &subparam, option, needAssignment); err != nil {
+				return err
+			}
+			continue
+		}
+
 		optidx := searchStructFieldParam(method.Operation, param.Name, &fields[idx])
 		if optidx < 0 {
 			return errors.New("param '" + param.Name + "." + fields[idx].Name +
@@ -933,7 +1514,29 @@ func (cmd *ClientGenerator) genInterfaceMethodParam(out io.Writer, method *Metho
 				}
 			}
 
-			if option.In == "header" {
+			if sep := collectionSeparator(option.CollectionFormat); sep != "" {
+				setFunc := "SetParam(\"" + option.Name + "\", "
+				if option.In == "header" {
+					header, _ := option.Extensions.GetString("x-gogen-header")
+					if header == "" {
+						header = option.Name
+					}
+					setFunc = "SetHeader(\"" + header + "\", "
+				}
+
+				io.WriteString(out, "\r\nif len("+param.Name+") > 0 {")
+				if typeName == "[]string" || (param.IsVariadic && typeName == "string") {
+					io.WriteString(out, "\r\n  request = request."+setFunc+"strings.Join("+param.Name+", "+strconv.Quote(sep)+"))")
+				} else {
+					io.WriteString(out, "\r\n  ss := make([]string, 0, len("+param.Name+"))")
+					io.WriteString(out, "\r\n  for idx := range "+param.Name+" {")
+					io.WriteString(out, "\r\n    ss = append(ss, "+convertToStringLiteral(param, "[idx]", cmd.config.ConvertNS, cmd.config.TimeFormat)+")")
+					io.WriteString(out, "\r\n  }")
+					io.WriteString(out, "\r\n  request = request."+setFunc+"strings.Join(ss, "+strconv.Quote(sep)+"))")
+				}
+				io.WriteString(out, "\r\n}")
+				*needAssignment = true
+			} else if option.In == "header" {
 				header, _ := option.Extensions.GetString("x-gogen-header")
 				if header == "" {
 					header = option.Name
@@ -1029,8 +1632,13 @@ type ClientConfig struct {
 	WrapperData  string
 	WrapperError string
 
+	ErrorMappings []ErrorMapping
+	ProblemType   string
+
 	newRequest     string
 	releaseRequest string
+	dialWebsocket  string
+	expectedStatus string
 }
 
 func (c *ClientConfig) NewRequest(proxy, url string) string {
@@ -1046,6 +1654,35 @@ func (c *ClientConfig) ReleaseRequest(proxy, request string) string {
 	})
 }
 
+func (c *ClientConfig) DialWebsocket(ctx, proxy, request string) string {
+	return renderString(c.dialWebsocket, map[string]interface{}{
+		"ctx":     ctx,
+		"proxy":   proxy,
+		"request": request,
+	})
+}
+
+// ExpectedStatus 返回设置成功状态码的调用, 状态码为 @Success 中列出的 2xx 状态码和 x-gogen-status-code,
+// 都没有时返回空
+func (c *ClientConfig) ExpectedStatus(method *Method) string {
+	if c.expectedStatus == "" {
+		return ""
+	}
+	var literals []string
+	for _, code := range successStatusCodes(method) {
+		literals = append(literals, statusCodeLiteral(code))
+	}
+	if withCode := WithCode(method); withCode != "" && !isExceptedType(withCode, literals) {
+		literals = append(literals, withCode)
+	}
+	if len(literals) == 0 {
+		return ""
+	}
+	return renderString(c.expectedStatus, map[string]interface{}{
+		"codes": strings.Join(literals, ", "),
+	})
+}
+
 func (c *ClientConfig) ResultName(method Method) string {
 	resultName := "result"
 	isNameExist := func(name string) bool {
@@ -1073,11 +1710,11 @@ func (c *ClientConfig) ResultName(method Method) string {
 }
 
 func (c *ClientConfig) RouteFunc(method *Method) string {
-	return method.Operation.RouterProperties[0].HTTPMethod
+	return method.PrimaryRoute().HTTPMethod
 }
 
 func (c *ClientConfig) GetPath(optionalRoutePrefix string, method *Method) string {
-	rawurl := method.Operation.RouterProperties[0].Path
+	rawurl := method.PrimaryRoute().Path
 	var replace = ReplaceFunc(func(segement PathSegement) string {
 		for idx := range method.Method.Params.List {
 			if FieldNameEqual(method.Method.Params.List[idx].Name, segement.Value) {
@@ -1109,6 +1746,50 @@ func (c *ClientConfig) GetPath(optionalRoutePrefix string, method *Method) strin
 	return strings.TrimSuffix("client.routePrefix() + \""+urlPath+"\"", "+ \"\"")
 }
 
+// needTextErr 判断参数或参数的字段中是否有用 MarshalText 转换为字符串的类型, 有时需要声明
+// textErr 来保存 MarshalText 返回的错误
+func needTextErr(method *Method) bool {
+	for _, param := range method.Method.Params.List {
+		if hasTextMarshalerField(param.Type(), 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTextMarshalerField(typ astutil.Type, depth int) bool {
+	if t := typ.PtrElemType(); t.IsValid() {
+		typ = t
+	}
+	if strings.HasPrefix(typ.ToLiteral(), "[]") {
+		typ = typ.GetElemType(false)
+		if t := typ.PtrElemType(); t.IsValid() {
+			typ = t
+		}
+	}
+	if isExceptedType(typ.ToLiteral(), bultinTypes) || typ.IsSqlNullableType() {
+		return false
+	}
+	if isTextUnmarshaler(typ) {
+		return hasMethod(typ, "MarshalText")
+	}
+	if depth > 10 || !typ.IsStructType() {
+		return false
+	}
+	ts, err := typ.ToTypeSpec(true)
+	if err != nil || ts.Struct == nil {
+		return false
+	}
+	fields := ts.Fields()
+	fields = append(fields, ts.Struct.Embedded...)
+	for idx := range fields {
+		if hasTextMarshalerField(fields[idx].Type(), depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
 func convertToStringLiteral(param *astutil.Param, index, convertNS, timeFormat string) string {
 	name := param.Name
 
@@ -1197,6 +1878,17 @@ retry:
 			}
 		}
 
+		if isFirst && isTextUnmarshaler(typ) {
+			if hasMethod(typ, "MarshalText") {
+				return "func() string {\r\n\tbs, err := " + name + ".MarshalText()" +
+					"\r\n\tif err != nil && textErr == nil {\r\n\t\ttextErr = err\r\n\t}" +
+					"\r\n\treturn string(bs)\r\n}()"
+			}
+			if hasMethod(typ, "String") {
+				return name + ".String()"
+			}
+		}
+
 		underlying := typ.GetUnderlyingType()
 		if underlying.IsValid() {
 			if isFirst {
@@ -1207,6 +1899,7 @@ retry:
 				if typeStr != "string" {
 					goto retry
 				}
+				return "string(" + name + ")"
 			}
 		}
 
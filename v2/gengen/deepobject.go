@@ -0,0 +1,490 @@
+package gengen
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/runner-mei/GoBatis/cmd/gobatis/goparser2/astutil"
+)
+
+// hasDeepObjectParam 判断方法中是否有需要按 deepObject 的方式来取值的参数
+func (method *Method) hasDeepObjectParam() bool {
+	for idx := range method.Method.Params.List {
+		param := &method.Method.Params.List[idx]
+		if _, ok := method.ContextValueKey(param.Name); ok {
+			continue
+		}
+		st := searchStructParam(method.Operation, param.Name)
+		if st == nil || st.In == "body" || st.In == "formData" {
+			continue
+		}
+		if hasDeepObjectField(param.Type(), 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDeepObjectField 判断 struct 中是否有需要按 deepObject 的方式来取值的字段, 包括嵌套的 struct 中的字段
+func hasDeepObjectField(typ astutil.Type, depth int) bool {
+	if t := typ.PtrElemType(); t.IsValid() {
+		typ = t
+	}
+	if depth > 10 || !typ.IsStructType() ||
+		isExceptedType(typ.ToLiteral(), bultinTypes) ||
+		typ.IsSqlNullableType() ||
+		isTextUnmarshaler(typ) {
+		return false
+	}
+	ts, err := typ.ToTypeSpec(true)
+	if err != nil || ts.Struct == nil {
+		return false
+	}
+	fields := ts.Fields()
+	fields = append(fields, ts.Struct.Embedded...)
+	for idx := range fields {
+		if isDeepObjectType(fields[idx].Type()) || hasDeepObjectField(fields[idx].Type(), depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderDeepObjectValues 在读取参数之前只解析一次查询参数, 供 BindDeepObject 使用
+func (method *Method) renderDeepObjectValues(ctx *GenContext) {
+	if !method.hasDeepObjectParam() {
+		return
+	}
+	values, _ := ctx.plugin.GetSpecificTypeArgument("url.Values")
+	io.WriteString(ctx.out, "\r\n\tdeepValues := "+values)
+}
+
+// renderFormDeepObjectParams 表单中 struct 的 slice 或 map 类型的字段不能由框架绑定,
+// 在读取 body 之后从已解析的表单中按 deepObject 的方式来取值
+func (method *Method) renderFormDeepObjectParams(ctx *GenContext, params []BodyParam) error {
+	var formParams []BodyParam
+	for idx := range params {
+		if params[idx].Option.In == "formData" &&
+			hasDeepObjectField(params[idx].Param.Type(), 0) {
+			formParams = append(formParams, params[idx])
+		}
+	}
+	if len(formParams) == 0 {
+		return nil
+	}
+
+	req, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+	io.WriteString(ctx.out, "\r\n\tif err := "+req+".ParseForm(); err != nil {\r\n")
+	ctx.plugin.RenderBodyError(ctx.out, method, formParams[0].Param.Name, "err")
+	io.WriteString(ctx.out, "\r\n\t}")
+	io.WriteString(ctx.out, "\r\n\tdeepForm := "+req+".PostForm")
+
+	// 只有一个参数并且它是整个 body 时, 表单中的参数名不带参数名前缀
+	isEntire := len(params) == 1 && isExtendEntire(params[0].Option)
+	for idx := range formParams {
+		goVarName := strings.TrimPrefix(method.goArgumentLiterals[formParams[idx].Index], "&")
+		webParamName := ""
+		if !isEntire {
+			webParamName = formParams[idx].Option.Name
+			if webParamName == "" {
+				webParamName = toSnakeCase(formParams[idx].Param.Name)
+			}
+		}
+
+		typ := formParams[idx].Param.Type()
+		if typ.IsPtrType() {
+			if !isEntire {
+				method.renderFormDeepObject(ctx, goVarName, webParamName)
+				continue
+			}
+			typ = typ.PtrElemType()
+		}
+		if err := method.renderFormDeepObjectFields(ctx, typ, goVarName, webParamName, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderFormDeepObjectFields 输出 struct 中需要按 deepObject 的方式来取值的字段的读取代码,
+// 指针类型的 struct 字段整个交给 BindDeepObject, 以免为没有值的字段分配内存
+func (method *Method) renderFormDeepObjectFields(ctx *GenContext, typ astutil.Type, goVarName, webParamName string, depth int) error {
+	ts, err := typ.ToTypeSpec(true)
+	if err != nil {
+		return errors.New("param '" + goVarName + "' of '" +
+			method.FullName() +
+			"' cannot convert to type spec: " + err.Error())
+	}
+	if ts.Struct == nil {
+		return nil
+	}
+
+	fields := ts.Fields()
+	fields = append(fields, ts.Struct.Embedded...)
+	for idx := range fields {
+		field := &fields[idx]
+		if s, _ := getTagValue(field, "swaggerignore"); strings.ToLower(s) == "true" {
+			continue
+		}
+
+		fieldGoName := goVarName
+		if !field.IsAnonymous {
+			fieldGoName = goVarName + "." + field.Name
+		}
+		jsonName, _ := getTagValue(field, "json")
+		jsonName = getJSONName(jsonName)
+		if jsonName == "" && !field.IsAnonymous {
+			jsonName = toSnakeCase(field.Name)
+		}
+		fieldWebName := webParamName
+		if jsonName != "" {
+			if fieldWebName != "" {
+				fieldWebName = fieldWebName + "."
+			}
+			fieldWebName = fieldWebName + jsonName
+		}
+
+		fieldType := field.Type()
+		if isDeepObjectType(fieldType) {
+			method.renderFormDeepObject(ctx, fieldGoName, fieldWebName)
+			continue
+		}
+		if !hasDeepObjectField(fieldType, depth+1) {
+			continue
+		}
+		if fieldType.IsPtrType() {
+			method.renderFormDeepObject(ctx, fieldGoName, fieldWebName)
+			continue
+		}
+		if err := method.renderFormDeepObjectFields(ctx, fieldType, fieldGoName, fieldWebName, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (method *Method) renderFormDeepObject(ctx *GenContext, goVarName, webParamName string) {
+	io.WriteString(ctx.out, "\r\n\tif err := "+ctx.convertNS+"BindDeepObject(deepForm, \""+webParamName+"\", &"+goVarName+"); err != nil {\r\n")
+	ctx.plugin.RenderCastError(ctx.out, method, webParamName, "\"\"", "err")
+	io.WriteString(ctx.out, "\r\n\t}")
+}
+
+const bindDeepObjectTxt = `// BindDeepObject 按 deepObject 的方式将 values 中以 prefix 开头的参数解析到 dst 中,
+// 参数名可以为 items[0][name] 或 items.0.name 的形式, 可以任意嵌套, slice 的下标只用于排序,
+// prefix 按同样的方式分解后再比较, 所以 filter.sub 可以匹配 filter[sub][a][b]
+func BindDeepObject(values url.Values, prefix string, dst interface{}) error {
+	prefixNames := splitDeepObjectKey(prefix)
+	root := &deepObjectNode{}
+	for key, vs := range values {
+		names := splitDeepObjectKey(key)
+		if len(names) <= len(prefixNames) {
+			continue
+		}
+		matched := true
+		for idx := range prefixNames {
+			if names[idx] != prefixNames[idx] {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		node := root
+		for _, name := range names[len(prefixNames):] {
+			node = node.child(name)
+		}
+		node.values = append(node.values, vs...)
+	}
+	if len(root.children) == 0 {
+		return nil
+	}
+	return root.bind(reflect.ValueOf(dst).Elem(), prefix)
+}
+
+type deepObjectNode struct {
+	values   []string
+	children map[string]*deepObjectNode
+}
+
+func (node *deepObjectNode) child(name string) *deepObjectNode {
+	if node.children == nil {
+		node.children = map[string]*deepObjectNode{}
+	}
+	c := node.children[name]
+	if c == nil {
+		c = &deepObjectNode{}
+		node.children[name] = c
+	}
+	return c
+}
+
+// splitDeepObjectKey 将 items[0][name] 或 items.0.name 分解为 items, 0 和 name
+func splitDeepObjectKey(s string) []string {
+	var names []string
+	for s != "" {
+		if s[0] == '[' {
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return append(names, s[1:])
+			}
+			names = append(names, s[1:end])
+			s = s[end+1:]
+			continue
+		}
+		if s[0] == '.' {
+			s = s[1:]
+		}
+		end := strings.IndexAny(s, ".[")
+		if end < 0 {
+			return append(names, s)
+		}
+		names = append(names, s[:end])
+		s = s[end:]
+	}
+	return names
+}
+
+func (node *deepObjectNode) bind(v reflect.Value, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(node.children) == 0 {
+		return setDeepObjectValue(v, node.values, path)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := deepObjectFields(v.Type())
+		for name, child := range node.children {
+			index, ok := fields[name]
+			if !ok {
+				continue
+			}
+			if err := child.bind(v.FieldByIndex(index), path+"."+name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		type item struct {
+			index int
+			node  *deepObjectNode
+		}
+		items := make([]item, 0, len(node.children))
+		for name, child := range node.children {
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 {
+				return fmt.Errorf("%s: index '%s' is invalid", path, name)
+			}
+			items = append(items, item{index: index, node: child})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].index < items[j].index })
+
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, it := range items {
+			if err := it.node.bind(slice.Index(i), path+"["+strconv.Itoa(it.index)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: key of %s isnot a string", path, v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for name, child := range node.children {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := child.bind(elem, path+"["+name+"]"); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: %s cannot have nested values", path, v.Type())
+	}
+}
+
+func setDeepObjectValue(v reflect.Value, values []string, path string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(values[len(values)-1])); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setDeepObjectValue(slice.Index(i), []string{s}, path); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	s := values[len(values)-1]
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		v.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("%s: %s is unsupported", path, v.Type())
+	}
+	return nil
+}
+
+// deepObjectFields 返回 struct 中字段的参数名(json tag 中的名称)到字段下标的映射, 匿名的 struct 字段会被展开
+func deepObjectFields(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for subname, index := range deepObjectFields(field.Type) {
+				if _, ok := fields[subname]; !ok {
+					fields[subname] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = []int{i}
+	}
+	return fields
+}`
+
+const deepObjectToValuesTxt = `// DeepObjectToValues 按 deepObject 的方式将 value 编码为 prefix[0][name]=a 形式的参数,
+// 简单类型的 slice 编码为多个同名的参数, MarshalText 出错的值会被忽略
+func DeepObjectToValues(prefix string, value interface{}) url.Values {
+	values := url.Values{}
+	addDeepObjectValues(values, prefix, reflect.ValueOf(value))
+	return values
+}
+
+func addDeepObjectValues(values url.Values, key string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if bs, err := m.MarshalText(); err == nil {
+			values.Add(key, string(bs))
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		addDeepObjectFields(values, key, v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(key, string(v.Bytes()))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			if isDeepObjectScalar(v.Type().Elem()) {
+				addDeepObjectValues(values, key, v.Index(i))
+			} else {
+				addDeepObjectValues(values, key+"["+strconv.Itoa(i)+"]", v.Index(i))
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+		for _, k := range keys {
+			addDeepObjectValues(values, key+"["+fmt.Sprint(k.Interface())+"]", v.MapIndex(k))
+		}
+	default:
+		values.Add(key, fmt.Sprint(v.Interface()))
+	}
+}
+
+func addDeepObjectFields(values url.Values, key string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addDeepObjectFields(values, key, v.Field(i))
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && v.Field(i).IsZero() {
+			continue
+		}
+		addDeepObjectValues(values, key+"["+name+"]", v.Field(i))
+	}
+}
+
+// isDeepObjectScalar 判断 slice 的元素是否为简单类型
+func isDeepObjectScalar(t reflect.Type) bool {
+	if t.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()) {
+		return true
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		return false
+	}
+	return true
+}`
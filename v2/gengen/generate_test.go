@@ -52,6 +52,9 @@ func TestGenerate(t *testing.T) {
 	testCases := []TestCase{
 		{
 			Name: "casetest",
+			Args: []string{
+				"-outputDeepObject",
+			},
 		},
 		{
 			Name: "test",
@@ -62,8 +65,24 @@ func TestGenerate(t *testing.T) {
 				"-httpCodeWith=errors.GetHttpCode",
 				"-badArgument=errors.NewBadArgument",
 				"-toEncodedError=errors.ToEncodedError",
+				"-recoverPanic=NewPanicError",
+				"-outputRecoverPanic",
 			},
 		},
+		{
+			Name: "problemtest",
+			Args: []string{
+				"-writeProblem=WriteProblem",
+				"-outputProblem",
+				"-routeInfo=RouteInfo",
+				"-outputRouteInfo",
+				"-observer=Observer",
+				"-outputObserver",
+			},
+		},
+		{
+			Name: "seqtest",
+		},
 	}
 	t.Run("gingen", func(t *testing.T) {
 		for _, test := range testCases {
@@ -291,7 +310,14 @@ func TestGenerate(t *testing.T) {
 	// })
 
 	t.Run("client", func(t *testing.T) {
-		for _, name := range []string{"casetest", "test"} {
+		for _, test := range []TestCase{
+			{Name: "casetest", Args: []string{"-output-deep-object",
+				"-dial-websocket", "DialWebsocket({{.ctx}},{{.proxy}},{{.request}})"}},
+			{Name: "test"},
+			{Name: "problemtest", Args: []string{"-problem-type=Problem"}},
+			{Name: "seqtest"},
+		} {
+			name := test.Name
 			t.Log("=====================", name)
 			os.Remove(filepath.Join(wd, "gentest", name+".client-gen.go"))
 			// fmt.Println(filepath.Join(wd, "gentest", name+".client-gen.go"))
@@ -299,7 +325,7 @@ func TestGenerate(t *testing.T) {
 			var gen = ClientGenerator{
 				ext: ".client-gen.go",
 			}
-			gen.Flags(flag.NewFlagSet("", flag.PanicOnError)).Parse([]string{})
+			gen.Flags(flag.NewFlagSet("", flag.PanicOnError)).Parse(test.Args)
 			gen.config.HasWrapper = false
 			gen.buildTag = "!loong"
 			// gen.includes = filepath.Join(wd, "gentest", "models", "requests.go")
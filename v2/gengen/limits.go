@@ -0,0 +1,170 @@
+package gengen
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// routeLimits 是用 @x-gogen-timeout, @x-gogen-max-body 和 @x-gogen-max-items 声明的限制, 为 0 时表示不限制
+type routeLimits struct {
+	timeout  time.Duration
+	maxBody  int64
+	maxItems int
+}
+
+// parse 解析 "@x-gogen-timeout 5s", "@x-gogen-max-body 1MB" 和 "@x-gogen-max-items 1000" 这样的行,
+// name 为小写的注解名
+func (limits *routeLimits) parse(name, value string) error {
+	line := name + " " + value
+	fields := strings.Fields(value)
+	switch name {
+	case "@x-gogen-timeout":
+		if len(fields) != 1 {
+			return errors.New("'" + line + "' is invalid, it must be '@x-gogen-timeout 5s'")
+		}
+		timeout, err := time.ParseDuration(fields[0])
+		if err != nil || timeout <= 0 {
+			return errors.New("'" + line + "' is invalid, timeout '" + fields[0] + "' is invalid")
+		}
+		limits.timeout = timeout
+	case "@x-gogen-max-body":
+		if len(fields) != 1 {
+			return errors.New("'" + line + "' is invalid, it must be '@x-gogen-max-body 1MB'")
+		}
+		size, err := parseByteSize(fields[0])
+		if err != nil {
+			return errors.New("'" + line + "' is invalid, " + err.Error())
+		}
+		limits.maxBody = size
+	case "@x-gogen-max-items":
+		if len(fields) != 1 {
+			return errors.New("'" + line + "' is invalid, it must be '@x-gogen-max-items 1000'")
+		}
+		count, err := strconv.Atoi(fields[0])
+		if err != nil || count <= 0 {
+			return errors.New("'" + line + "' is invalid, count '" + fields[0] + "' is invalid")
+		}
+		limits.maxItems = count
+	}
+	return nil
+}
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseByteSize 解析 "1MB", "512KB" 和 "1024" 这样的大小, 单位都是 1024 的倍数
+func parseByteSize(s string) (int64, error) {
+	number, unit := strings.ToUpper(s), int64(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("size '" + s + "' is invalid")
+	}
+	if n > math.MaxInt64/unit {
+		return 0, errors.New("size '" + s + "' is too large")
+	}
+	return n * unit, nil
+}
+
+// durationLiteral 返回 d 的 go 表达式, 如 5*time.Second
+func durationLiteral(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"time.Hour", time.Hour},
+		{"time.Minute", time.Minute},
+		{"time.Second", time.Second},
+		{"time.Millisecond", time.Millisecond},
+		{"time.Microsecond", time.Microsecond},
+	}
+	for _, u := range units {
+		if d%u.size == 0 {
+			return strconv.FormatInt(int64(d/u.size), 10) + "*" + u.name
+		}
+	}
+	return "time.Duration(" + strconv.FormatInt(int64(d), 10) + ")"
+}
+
+// renderLimits 在处理请求之前给请求的 context 加上超时, 并限制请求体的大小
+func (method *Method) renderLimits(ctx *GenContext) {
+	if method.limits.timeout > 0 {
+		stdctx, _ := ctx.plugin.GetSpecificTypeArgument("context.Context")
+		io.WriteString(ctx.out, "\r\n\ttimeoutCtx, cancel := context.WithTimeout("+stdctx+", "+durationLiteral(method.limits.timeout)+")")
+		io.WriteString(ctx.out, "\r\n\tdefer cancel()")
+		ctx.plugin.RenderSetContext(ctx.out, "timeoutCtx")
+	}
+	if method.limits.maxBody > 0 {
+		r, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+		w, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+		io.WriteString(ctx.out, "\r\n\t"+r+".Body = http.MaxBytesReader("+w+", "+r+".Body, "+strconv.FormatInt(method.limits.maxBody, 10)+")")
+	}
+}
+
+// renderMaxItemsCheck 检查查询参数中数组的长度是否超过了 @x-gogen-max-items, values 为空时检查参数的值,
+// 已经在转换之前检查过原始的值时不再检查
+func (method *Method) renderMaxItemsCheck(ctx *GenContext, param *Param, values string) error {
+	if method.limits.maxItems <= 0 || param.option == nil || param.option.In != "query" {
+		return nil
+	}
+	if !param.IsVariadic && !param.Type().IsSliceType() {
+		return nil
+	}
+
+	goVarName := GetGoVarName(param, nil, true)
+	if values == "" {
+		if ctx.itemsChecked[goVarName] {
+			return nil
+		}
+		values = goVarName
+	} else {
+		if ctx.itemsChecked == nil {
+			ctx.itemsChecked = map[string]bool{}
+		}
+		ctx.itemsChecked[goVarName] = true
+	}
+	webParamName := GetWebParamName(param, nil)
+	maxItems := strconv.Itoa(method.limits.maxItems)
+
+	io.WriteString(ctx.out, "\r\n\tif len("+values+") > "+maxItems+" {\r\n")
+	ctx.plugin.RenderCastError(ctx.out, method, webParamName, "\"\"",
+		"errors.New(\"the number of items exceeds "+maxItems+"\")")
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
+// bodyLimitedPlugin 在读取请求体出错时, 如果是超过了 @x-gogen-max-body 的限制则返回 413
+type bodyLimitedPlugin struct {
+	Plugin
+}
+
+func (p bodyLimitedPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
+	io.WriteString(out, "\tif errors.As("+err+", new(*http.MaxBytesError)) {\r\n")
+	if e := p.Plugin.RenderReturnError(out, method, "http.StatusRequestEntityTooLarge", err); e != nil {
+		return e
+	}
+	io.WriteString(out, "\r\n\t}\r\n")
+	return p.Plugin.RenderBodyError(out, method, bodyName, err)
+}
@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-openapi/spec"
 	"github.com/runner-mei/GoBatis/cmd/gobatis/goparser2/astutil"
@@ -15,15 +19,74 @@ import (
 type GenContext struct {
 	enableResultWrap bool
 	convertNS        string
-	plugin           Plugin
-	out              io.Writer
+	badArgument      string
+	itemsChecked     map[string]bool
+	errorMappings    []ErrorMapping
+	securitySchemes  map[string]*spec.SecurityScheme
+	observed         bool
+	recoverPanic     string
+	// websocket 发送 ping 消息的间隔和超时时间
+	websocketPingPeriod time.Duration
+	websocketWriteWait  time.Duration
+	plugin              Plugin
+	out                 io.Writer
 }
 
 var specificParamName = "otherValues"
 
+// collectAstFiles 将要生成的文件, 以及其中的类型所嵌入的接口所在的文件(可能在其它文件或其它包中)
+// 都交给 swag, 最后只调用一次 ParseTypes, 这样 resolveMethods 中 swag 能找到所有用到的类型
+func collectAstFiles(swaggerParser *swag.Parser, files []*astutil.File) error {
+	collected := map[string]bool{}
+	collect := func(file *astutil.File) error {
+		if file == nil || file.Package == nil || collected[file.Filename] {
+			return nil
+		}
+		collected[file.Filename] = true
+		err := swaggerParser.Packages().CollectAstFile(file.Package.ImportPath, file.Filename, file.AstFile)
+		if err != nil {
+			return errors.New("collect astFile: " + err.Error())
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		if err := collect(file); err != nil {
+			return err
+		}
+		typeList, err := instantiateTypes(file.TypeList)
+		if err != nil {
+			return err
+		}
+		for _, ts := range typeList {
+			if (ts.Struct == nil && ts.Interface == nil) || ts.Node.TypeParams != nil {
+				continue
+			}
+			_, owners, err := typeMethods(ts)
+			if err != nil {
+				return err
+			}
+			for _, owner := range owners {
+				if err := collect(owner); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if _, err := swaggerParser.Packages().ParseTypes(); err != nil {
+		return errors.New("parse types: " + err.Error())
+	}
+	return nil
+}
+
 func resolveMethods(swaggerParser *swag.Parser, ts *astutil.TypeSpec) ([]*Method, error) {
 	var methods []*Method
-	list := ts.Methods()
+	list, files, err := typeMethods(ts)
+	if err != nil {
+		return nil, err
+	}
+
 	for idx, method := range list {
 		var doc = method.Doc()
 
@@ -35,27 +98,394 @@ func resolveMethods(swaggerParser *swag.Parser, ts *astutil.TypeSpec) ([]*Method
 			})
 			continue
 		}
+		var mappings []ErrorMapping
+		var contextValues map[string]string
+		var middlewares []string
+		var limits routeLimits
 		for _, comment := range doc.List {
-			err := operation.ParseComment(comment.Text, ts.File.AstFile)
+			line := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			name, value := line, ""
+			if pos := strings.IndexAny(line, " \t"); pos > 0 {
+				name, value = line[:pos], strings.TrimSpace(line[pos+1:])
+			}
+
+			// 这些 @x-gogen-* 的值都不是 json, 所以不交给 swag 解析
+			var err error
+			switch name = strings.ToLower(name); name {
+			case "@x-gogen-error":
+				// 可以有多行, 格式为 "@x-gogen-error ErrNotFound 404"
+				var mapping ErrorMapping
+				mapping, err = parseErrorMapping(value)
+				if err == nil {
+					mappings = append(mappings, mapping)
+				}
+			case "@x-gogen-middleware":
+				// 格式为 "@x-gogen-middleware audit,rateLimit"
+				middlewares = append(middlewares, parseMiddlewares(value)...)
+			case "@x-gogen-context":
+				// 格式为 "@x-gogen-context 参数名 context中的key"
+				var param, key string
+				param, key, err = parseContextValue(list[idx].Params, value)
+				if err == nil {
+					if contextValues == nil {
+						contextValues = map[string]string{}
+					}
+					contextValues[param] = key
+				}
+			case "@x-gogen-timeout", "@x-gogen-max-body", "@x-gogen-max-items":
+				// 如 "@x-gogen-timeout 5s"
+				err = limits.parse(name, value)
+			default:
+				err = operation.ParseComment(comment.Text, files[idx].AstFile)
+			}
 			if err != nil {
 				return nil, fmt.Errorf(method.PostionString()+": ParseComment error:%+v", err)
 			}
 		}
 
+		if _, err := primaryRouteIndex(operation); err != nil {
+			return nil, errors.New(method.PostionString() + ": " + err.Error())
+		}
+
 		methods = append(methods, &Method{
-			Method:    &list[idx],
-			Operation: operation,
+			Method:        &list[idx],
+			Operation:     operation,
+			errorMappings: mappings,
+			contextValues: contextValues,
+			middlewares:   middlewares,
+			limits:        limits,
 		})
 	}
 	return methods, nil
 }
 
+// instantiateTypes 在泛型类型后面加上用 @gogen.instantiate 声明的实例, 如
+//
+//	// @gogen.instantiate Store[User] as UserStore
+//	type Store[T any] interface { ... }
+//
+// 实例被当作 type UserStore = Store[User] 来处理, 它的方法来自于嵌入的 Store[User],
+// 方法中的类型参数都被替换为实际的类型
+func instantiateTypes(typeList []*astutil.TypeSpec) ([]*astutil.TypeSpec, error) {
+	var results []*astutil.TypeSpec
+	for _, ts := range typeList {
+		results = append(results, ts)
+
+		doc := ts.Doc()
+		if ts.Node.TypeParams == nil || doc == nil {
+			continue
+		}
+		for _, comment := range doc.List {
+			line := strings.TrimSpace(strings.TrimLeft(comment.Text, "/"))
+			if !strings.HasPrefix(line, "@gogen.instantiate") {
+				continue
+			}
+			instance, err := instantiateType(ts, strings.TrimSpace(strings.TrimPrefix(line, "@gogen.instantiate")))
+			if err != nil {
+				return nil, errors.New(ts.File.PostionFor(comment.Pos()).String() + ": " + err.Error())
+			}
+			results = append(results, instance)
+		}
+	}
+	return results, nil
+}
+
+func instantiateType(ts *astutil.TypeSpec, text string) (*astutil.TypeSpec, error) {
+	idx := strings.LastIndex(text, " as ")
+	if idx < 0 {
+		return nil, errors.New("'@gogen.instantiate " + text + "' is invalid, it must be '@gogen.instantiate " + ts.Name + "[...] as Name'")
+	}
+	name := strings.TrimSpace(text[idx+len(" as "):])
+	if !token.IsIdentifier(name) {
+		return nil, errors.New("'@gogen.instantiate " + text + "' is invalid, '" + name + "' isnot a identifier")
+	}
+
+	expr, err := parser.ParseExpr(strings.TrimSpace(text[:idx]))
+	if err != nil {
+		return nil, errors.New("'@gogen.instantiate " + text + "' is invalid, " + err.Error())
+	}
+	var typ ast.Expr
+	var args []ast.Expr
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		typ, args = t.X, []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		typ, args = t.X, t.Indices
+	}
+	if ident, ok := typ.(*ast.Ident); !ok || ident.Name != ts.Name {
+		return nil, errors.New("'@gogen.instantiate " + text + "' is invalid, it must be '@gogen.instantiate " + ts.Name + "[...] as Name'")
+	}
+	if count := ts.Node.TypeParams.NumFields(); count != len(args) {
+		return nil, fmt.Errorf("'@gogen.instantiate %s' is invalid, want %d type arguments, got %d", text, count, len(args))
+	}
+
+	instance := &astutil.TypeSpec{
+		File: ts.File,
+		Node: &ast.TypeSpec{
+			Doc:     ts.Node.Doc,
+			Comment: ts.Node.Comment,
+			Name:    &ast.Ident{NamePos: ts.Node.Name.NamePos, Name: name},
+			Assign:  ts.Node.Name.End(),
+			Type:    expr,
+		},
+		Name: name,
+	}
+	if ts.Struct != nil {
+		instance.Struct = &astutil.Struct{
+			Embedded: []astutil.Field{{Clazz: instance, Expr: expr, IsAnonymous: true}},
+		}
+	} else {
+		instance.Interface = &astutil.Interface{
+			Embedded: []ast.Node{expr},
+		}
+	}
+	return instance, nil
+}
+
+// serviceTypeName 返回 Init 函数中服务参数的类型, 泛型的实例返回如 Store[User]
+func serviceTypeName(ts *astutil.TypeSpec) string {
+	name := ts.Name
+	if ts.Node.Assign.IsValid() {
+		name = astutil.ToString(ts.Node.Type)
+	}
+	if ts.Struct != nil {
+		return "*" + name
+	}
+	return name
+}
+
+// typeMethods 返回类型的所有方法, 包括嵌入的接口(可以是其它包中的)和嵌入的结构中的方法,
+// 同名时外层的方法优先, 同时返回每个方法所在的文件, 用于解析它的注释
+func typeMethods(ts *astutil.TypeSpec) ([]astutil.Method, []*astutil.File, error) {
+	var list []astutil.Method
+	var files []*astutil.File
+	seen := map[string]bool{}
+	visited := map[*astutil.TypeSpec]bool{}
+
+	var collect func(owner *astutil.TypeSpec, typeArgs map[string]ast.Expr, qualifier string) error
+	collect = func(owner *astutil.TypeSpec, typeArgs map[string]ast.Expr, qualifier string) error {
+		if visited[owner] {
+			return nil
+		}
+		visited[owner] = true
+
+		for _, method := range owner.Methods() {
+			if seen[method.Name] {
+				continue
+			}
+			seen[method.Name] = true
+			if owner != ts {
+				method = inheritMethod(ts, owner, method, typeArgs, qualifier)
+			}
+			list = append(list, method)
+			files = append(files, owner.File)
+		}
+
+		var embedded []ast.Expr
+		if owner.Interface != nil {
+			for _, node := range owner.Interface.Embedded {
+				if expr, ok := node.(ast.Expr); ok {
+					embedded = append(embedded, expr)
+				}
+			}
+		}
+		if owner.Struct != nil {
+			for _, field := range owner.Struct.Embedded {
+				embedded = append(embedded, field.Expr)
+			}
+		}
+
+		for _, expr := range embedded {
+			typ := expr
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			var args []ast.Expr
+			switch t := typ.(type) {
+			case *ast.IndexExpr:
+				typ, args = t.X, []ast.Expr{t.Index}
+			case *ast.IndexListExpr:
+				typ, args = t.X, t.Indices
+			}
+			if ident, ok := typ.(*ast.Ident); ok && !ast.IsExported(ident.Name) && owner.File.Ctx.FindTypeInPackage(owner.File, ident.Name) == nil {
+				// error, any 之类的内置类型
+				continue
+			}
+
+			embeddedTs, err := owner.File.Ctx.ToTypeSpec(owner.File, typ, true)
+			if err != nil {
+				if owner.Struct != nil {
+					// 结构中嵌入的字段不一定是服务, 找不到时忽略它
+					continue
+				}
+				return errors.New(owner.File.PostionFor(expr.Pos()).String() + ": embedded interface '" + astutil.ToString(expr) + "' isnot found, " + err.Error())
+			}
+			if embeddedTs.File == nil {
+				continue
+			}
+
+			embeddedQualifier := qualifier
+			if sel, ok := typ.(*ast.SelectorExpr); ok {
+				embeddedQualifier = astutil.ToString(sel.X)
+			}
+
+			var embeddedTypeArgs map[string]ast.Expr
+			if embeddedTs.Node.TypeParams != nil {
+				embeddedTypeArgs = map[string]ast.Expr{}
+				idx := 0
+				for _, field := range embeddedTs.Node.TypeParams.List {
+					for _, name := range field.Names {
+						if idx >= len(args) {
+							return errors.New(owner.File.PostionFor(expr.Pos()).String() + ": type arguments of '" + astutil.ToString(expr) + "' is missing")
+						}
+						embeddedTypeArgs[name.Name] = rewriteTypeExpr(args[idx], typeArgs, qualifier)
+						idx++
+					}
+				}
+			}
+
+			if err := collect(embeddedTs, embeddedTypeArgs, embeddedQualifier); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := collect(ts, nil, ""); err != nil {
+		return nil, nil, err
+	}
+
+	for idx := range list {
+		list[idx].Params.Method = &list[idx]
+		for j := range list[idx].Params.List {
+			list[idx].Params.List[j].Method = &list[idx]
+		}
+		list[idx].Results.Method = &list[idx]
+		for j := range list[idx].Results.List {
+			list[idx].Results.List[j].Method = &list[idx]
+		}
+	}
+	return list, files, nil
+}
+
+// inheritMethod 复制嵌入类型中的方法, 方法属于外层的类型, 而类型参数被替换为实际的类型,
+// 嵌入的类型在其它包中时给包中的导出类型加上包名, 方法的位置仍然是它原来的位置
+func inheritMethod(outer, owner *astutil.TypeSpec, method astutil.Method, typeArgs map[string]ast.Expr, qualifier string) astutil.Method {
+	var fnType *ast.FuncType
+	if method.Node != nil {
+		fnType, _ = method.Node.Type.(*ast.FuncType)
+	} else if method.NodeDecl != nil {
+		fnType = method.NodeDecl.Type
+	}
+
+	clazz := *owner
+	clazz.Name = outer.Name
+	if qualifier != "" {
+		clazz.File = outer.File
+	}
+	method.Clazz = &clazz
+	method.Function = astutil.ToFunction(rewriteTypeExpr(fnType, typeArgs, qualifier).(*ast.FuncType))
+	return method
+}
+
+// rewriteTypeExpr 复制类型表达式, 将类型参数替换为 typeArgs 中的类型, qualifier 不为空时给导出的类型加上包名
+func rewriteTypeExpr(expr ast.Expr, typeArgs map[string]ast.Expr, qualifier string) ast.Expr {
+	rewrite := func(e ast.Expr) ast.Expr {
+		if e == nil {
+			return nil
+		}
+		return rewriteTypeExpr(e, typeArgs, qualifier)
+	}
+	rewriteFields := func(list *ast.FieldList) *ast.FieldList {
+		if list == nil {
+			return nil
+		}
+		copyed := &ast.FieldList{Opening: list.Opening, Closing: list.Closing}
+		for _, field := range list.List {
+			copyed.List = append(copyed.List, &ast.Field{
+				Doc:     field.Doc,
+				Names:   field.Names,
+				Type:    rewrite(field.Type),
+				Tag:     field.Tag,
+				Comment: field.Comment,
+			})
+		}
+		return copyed
+	}
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if arg, ok := typeArgs[t.Name]; ok {
+			return arg
+		}
+		if qualifier != "" && ast.IsExported(t.Name) {
+			return &ast.SelectorExpr{X: ast.NewIdent(qualifier), Sel: ast.NewIdent(t.Name)}
+		}
+		return t
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: rewrite(t.X)}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{X: rewrite(t.X)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: t.Len, Elt: rewrite(t.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: rewrite(t.Key), Value: rewrite(t.Value)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: t.Dir, Value: rewrite(t.Value)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: rewrite(t.Elt)}
+	case *ast.IndexExpr:
+		return &ast.IndexExpr{X: rewrite(t.X), Index: rewrite(t.Index)}
+	case *ast.IndexListExpr:
+		indices := make([]ast.Expr, 0, len(t.Indices))
+		for _, index := range t.Indices {
+			indices = append(indices, rewrite(index))
+		}
+		return &ast.IndexListExpr{X: rewrite(t.X), Indices: indices}
+	case *ast.FuncType:
+		return &ast.FuncType{TypeParams: t.TypeParams, Params: rewriteFields(t.Params), Results: rewriteFields(t.Results)}
+	default:
+		return expr
+	}
+}
+
 type Method struct {
 	Method    *astutil.Method
 	Operation *swag.Operation
 
 	errorDeclared      bool
+	errorMappings      []ErrorMapping
+	contextValues      map[string]string
+	middlewares        []string
+	limits             routeLimits
 	goArgumentLiterals []string
+
+	// route 是正在输出处理函数的路由, 为 nil 时表示处理函数由所有的路由共用
+	route *swag.RouteProperties
+}
+
+// ContextValueKey 返回用 @x-gogen-context 声明的参数在 context 中的 key,
+// 这样的参数由中间件放在请求的 context 中, 客户端方法中没有它们
+func (method *Method) ContextValueKey(name string) (string, bool) {
+	key, ok := method.contextValues[name]
+	return key, ok
+}
+
+// parseContextValue 解析 "tenantID tenant.Key" 这样的值, key 是一个 go 表达式
+func parseContextValue(params *astutil.Params, s string) (string, string, error) {
+	ss := strings.Fields(s)
+	if len(ss) < 2 {
+		return "", "", errors.New("'@x-gogen-context " + s + "' is invalid, it must be '@x-gogen-context name key'")
+	}
+	name, key := ss[0], strings.Join(ss[1:], " ")
+	if params != nil {
+		for idx := range params.List {
+			if params.List[idx].Name == name {
+				return name, key, nil
+			}
+		}
+	}
+	return "", "", errors.New("'@x-gogen-context " + s + "' is invalid, param '" + name + "' isnot found")
 }
 
 func (method *Method) SetErrorDeclared() {
@@ -77,6 +507,14 @@ func (method *Method) NoReturn() bool {
 	}
 	return noreturn
 }
+func (method *Method) IsWebsocket() bool {
+	websocket := false
+	if o := method.Operation.Extensions["x-gogen-websocket"]; o != nil {
+		websocket = strings.ToLower(fmt.Sprint(o)) == "true"
+	}
+	return websocket
+}
+
 func HasResultWrap(method *Method) bool {
 	value := ""
 	if o := method.Operation.Extensions["x-gogen-result-wrap"]; o != nil {
@@ -93,6 +531,49 @@ func WithCode(method *Method) string {
 	return withCode
 }
 
+// PrimaryRoute 返回客户端使用的路由, 有多个 @Router 时默认为第一个, 也可以用
+// @x-gogen-primary-route "/users/{id}" 指定它的路径
+func (method *Method) PrimaryRoute() swag.RouteProperties {
+	idx, _ := primaryRouteIndex(method.Operation)
+	return method.Operation.RouterProperties[idx]
+}
+
+// routeHTTPMethod 返回正在输出处理函数的路由的 HTTP 方法, 处理函数由所有的路由共用时为 PrimaryRoute 的
+func (method *Method) routeHTTPMethod() string {
+	if method.route != nil {
+		return method.route.HTTPMethod
+	}
+	return method.PrimaryRoute().HTTPMethod
+}
+
+// defaultStatusVaries 判断多个路由的默认成功状态码是否不同(如同时有 GET 和 POST), 这时处理函数不能共用
+func (method *Method) defaultStatusVaries() bool {
+	if WithCode(method) != "" || len(successStatusCodes(method)) > 0 {
+		return false
+	}
+	for _, route := range method.Operation.RouterProperties {
+		if statusCodeLiteralByMethod(route.HTTPMethod) != statusCodeLiteralByMethod(method.PrimaryRoute().HTTPMethod) {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryRouteIndex 返回 @x-gogen-primary-route 指定的路由的位置, 它不是 @Router 中的路径时返回错误
+func primaryRouteIndex(operation *swag.Operation) (int, error) {
+	o := operation.Extensions["x-gogen-primary-route"]
+	if o == nil {
+		return 0, nil
+	}
+	pa := fmt.Sprint(o)
+	for idx, route := range operation.RouterProperties {
+		if route.Path == pa {
+			return idx, nil
+		}
+	}
+	return 0, errors.New("'@x-gogen-primary-route " + pa + "' is invalid, it isnot found in the @Router")
+}
+
 func searchParam(operation *swag.Operation, paramName string) int {
 	for i := range operation.Parameters {
 		oname := operation.Parameters[i].Name
@@ -187,6 +668,14 @@ func defaultValue(resultType string, param *Param, parents []*Field) string {
 func (method *Method) renderImpl(ctx *GenContext) error {
 	method.goArgumentLiterals = make([]string, len(method.Method.Params.List))
 
+	if err := method.renderRecover(ctx); err != nil {
+		return err
+	}
+	method.renderLimits(ctx)
+	method.renderAuthenticate(ctx)
+
+	method.renderDeepObjectValues(ctx)
+
 	var inBody []BodyParam
 
 	for idx := range method.Method.Params.List {
@@ -194,6 +683,15 @@ func (method *Method) renderImpl(ctx *GenContext) error {
 
 		paramType := param.Type()
 
+		if key, ok := method.ContextValueKey(param.Name); ok {
+			method.goArgumentLiterals[idx] = param.Name
+			err := method.renderContextValueParam(ctx, param, key)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		switch paramType.ToLiteral() {
 		case "map[string]string":
 			st := searchStructParam(method.Operation, param.Name)
@@ -266,6 +764,20 @@ func (method *Method) renderImpl(ctx *GenContext) error {
 			continue
 		}
 
+		if method.IsWebsocket() {
+			if inIdx, elemType := websocketInParam(method); inIdx == idx {
+				method.goArgumentLiterals[idx] = "in"
+				io.WriteString(ctx.out, "\r\n\tin := make(chan "+elemType+")")
+				continue
+			}
+		}
+
+		if yieldIdx, elemType := yieldParam(method); yieldIdx == idx {
+			method.goArgumentLiterals[idx] = "yield"
+			method.renderNDJSONYield(ctx, elemType)
+			continue
+		}
+
 		foundIndex := searchParam(method.Operation, param.Name)
 		if foundIndex >= 0 {
 			if method.Operation.Parameters[foundIndex].In == "body" ||
@@ -331,6 +843,44 @@ func (method *Method) renderImpl(ctx *GenContext) error {
 	return method.renderInvokeAndReturn(ctx)
 }
 
+// context 中没有值时说明请求没有经过设置它的中间件(一般是认证), 按未认证处理,
+// 类型不对则是服务端的配置错误
+const (
+	contextValueMissingStatus  = "http.StatusUnauthorized"
+	contextValueMismatchStatus = "http.StatusInternalServerError"
+)
+
+// renderContextValueParam 从请求的 context 中取出参数的值, 没有时返回 contextValueMissingStatus,
+// 类型不对时返回 contextValueMismatchStatus
+func (method *Method) renderContextValueParam(ctx *GenContext, param *astutil.Param, key string) error {
+	stdctx, ok := ctx.plugin.GetSpecificTypeArgument("context.Context")
+	if !ok {
+		return errors.New("param '" + param.Name +
+			"' of '" + method.FullName() +
+			"' cannot read from the context")
+	}
+	typeStr := param.Type().ToLiteral()
+	valueName := param.Name + "Value"
+
+	io.WriteString(ctx.out, "\r\n\t"+valueName+" := "+stdctx+".Value("+key+")")
+	io.WriteString(ctx.out, "\r\n\tif "+valueName+" == nil {\r\n\t\t")
+	err := renderReturnRejected(ctx, method, param.Name, contextValueMissingStatus,
+		"errors.New(\"'"+param.Name+"' isnot found in the context\")")
+	if err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+	io.WriteString(ctx.out, "\r\n\t"+param.Name+", ok := "+valueName+".("+typeStr+")")
+	io.WriteString(ctx.out, "\r\n\tif !ok {\r\n\t\t")
+	err = renderReturnRejected(ctx, method, param.Name, contextValueMismatchStatus,
+		"errors.New(\"'"+param.Name+"' in the context isnot a "+strings.Replace(typeStr, "\"", "\\\"", -1)+"\")")
+	if err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
 func (method *Method) HasQueryParam() bool {
 	for idx := range method.Method.Params.List {
 		param := &method.Method.Params.List[idx]
@@ -421,10 +971,45 @@ func isPrefixForType(typ astutil.Type) bool {
 	} else if s := typ.ToLiteral(); s == "map[string]string" ||
 		s == "url.Values" {
 		return true
+	} else if isDeepObjectType(typ) {
+		return true
 	}
 	return false
 }
 
+// isDeepObjectType 判断是否为 struct 的 slice 或 map 类型(元素也可以是这样的 slice 或 map,
+// 如 map[string][]T), 这些类型不能展开成固定的参数名，需要按 deepObject 的方式来编解码
+func isDeepObjectType(typ astutil.Type) bool {
+	if t := typ.PtrElemType(); t.IsValid() {
+		typ = t
+	}
+
+	var elmType astutil.Type
+	if typ.IsSliceType() {
+		elmType = typ.SliceElemType()
+	} else if typ.IsMapType() {
+		if s := typ.ToLiteral(); s == "map[string]string" ||
+			s == "url.Values" {
+			return false
+		}
+		elmType = typ.MapValueType()
+	} else {
+		return false
+	}
+	if !elmType.IsValid() {
+		return false
+	}
+	if t := elmType.PtrElemType(); t.IsValid() {
+		elmType = t
+	}
+	if elmType.IsSliceType() || elmType.IsMapType() {
+		return isDeepObjectType(elmType)
+	}
+	return elmType.IsStructType() &&
+		!elmType.IsSqlNullableType() &&
+		!isExceptedType(elmType.ToLiteral(), bultinTypes)
+}
+
 func getFieldSiblingNames(typ astutil.Type) ([]SiblingName, error) {
 	if t := typ.PtrElemType(); t.IsValid() {
 		typ = t
@@ -576,7 +1161,8 @@ func (method *Method) renderStructParam(ctx *GenContext, param *Param, parents [
 
 		if fieldType.IsStructType() &&
 			!isNullableType &&
-			!isExceptedType(fieldType.ToLiteral(), bultinTypes) {
+			!isExceptedType(fieldType.ToLiteral(), bultinTypes) &&
+			!isTextUnmarshaler(fieldType) {
 			err = method.renderStructParam(ctx, param,
 				append(parents, &Field{
 					Field:        &fields[idx],
@@ -588,8 +1174,20 @@ func (method *Method) renderStructParam(ctx *GenContext, param *Param, parents [
 			continue
 		}
 
+		if isDeepObjectType(fieldType) {
+			err = method.renderDeepObjectParam(ctx, param,
+				append(parents, &Field{
+					Field:        &fields[idx],
+					isFirstField: idx == 0,
+				}))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		optidx := searchStructFieldParam(method.Operation, GetGoVarName(param, parents, true), &fields[idx])
-		if optidx < 0 {
+		if optidx < 0 && !isHeaderField(&fields[idx]) {
 			return errors.New("param '" + GetGoVarName(param, parents, true) + "." + fields[idx].Name +
 				"' of '" + method.FullName() +
 				"' not found in the swagger1 annotations")
@@ -617,17 +1215,38 @@ func (method *Method) renderStructParam(ctx *GenContext, param *Param, parents [
 			continue
 		}
 
-		if err := method.renderPrimitiveTypeParam(ctx, param,
-			append(parents, &Field{
-				Field:        &fields[idx],
-				isFirstField: idx == 0,
-			})); err != nil {
+		field := &Field{
+			Field:        &fields[idx],
+			isFirstField: idx == 0,
+		}
+		if err := method.renderPrimitiveTypeParam(ctx, param, append(parents, field)); err != nil {
+			return err
+		}
+		if err := method.renderEnumCheck(ctx, param, append(parents, field)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// renderDeepObjectParam 输出 struct 的 slice 或 map 类型的字段的读取代码，
+// 如 items[0][name]=a 或 items.0.name=a，具体的解析由 BindDeepObject 函数完成,
+// 查询参数已经由 renderDeepObjectValues 解析到 deepValues 中
+func (method *Method) renderDeepObjectParam(ctx *GenContext, param *Param, fields []*Field) error {
+	goVarName := GetGoVarName(param, fields, true)
+	webParamName := GetWebParamName(param, fields)
+
+	if err := renderParentInit(ctx, param, fields, false); err != nil {
+		return err
+	}
+	setParentInitialized(param, fields)
+
+	io.WriteString(ctx.out, "\r\n\tif err := "+ctx.convertNS+"BindDeepObject(deepValues, \""+webParamName+"\", &"+goVarName+"); err != nil {\r\n")
+	ctx.plugin.RenderCastError(ctx.out, method, webParamName, "\"\"", "err")
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
 func (method *Method) renderSimpleParam(ctx *GenContext, param *Param) error {
 	typ := param.Type()
 	isPtrType := false
@@ -652,7 +1271,64 @@ func (method *Method) renderSimpleParam(ctx *GenContext, param *Param) error {
 		return nil
 	}
 
-	return method.renderPrimitiveTypeParam(ctx, param, nil)
+	if err := method.renderPrimitiveTypeParam(ctx, param, nil); err != nil {
+		return err
+	}
+	if err := method.renderEnumCheck(ctx, param, nil); err != nil {
+		return err
+	}
+	return method.renderMaxItemsCheck(ctx, param, "")
+}
+
+// renderEnumCheck 当参数的类型在包中声明了同类型的常量时，检查参数值是否为这些常量之一
+func (method *Method) renderEnumCheck(ctx *GenContext, param *Param, fields []*Field) error {
+	required := false
+	var typ astutil.Type
+	if len(fields) == 0 {
+		typ = param.Type()
+		required = param.option.In == "path"
+	} else {
+		typ = fields[len(fields)-1].Type()
+	}
+
+	isArray := len(fields) == 0 && param.IsVariadic
+	if typ.IsSliceType() {
+		isArray = true
+		typ = typ.SliceElemType()
+	}
+
+	values := enumValues(typ)
+	if len(values) == 0 {
+		return nil
+	}
+
+	goVarName := GetGoVarName(param, fields, true)
+	webParamName := GetWebParamName(param, fields)
+
+	valueExpr := goVarName
+	if isArray {
+		valueExpr = "v"
+		io.WriteString(ctx.out, "\r\n\tfor _, v := range "+goVarName+" {")
+	} else {
+		if len(fields) == 0 && method.goArgumentLiterals[param.index] != "" {
+			valueExpr = method.goArgumentLiterals[param.index]
+		}
+		if !required {
+			io.WriteString(ctx.out, "\r\n\tif "+valueExpr+" != "+zeroValueLiteral(typ)+" {")
+		}
+	}
+
+	io.WriteString(ctx.out, "\r\n\tswitch "+valueExpr+" {")
+	io.WriteString(ctx.out, "\r\n\tcase "+strings.Join(values, ", ")+":")
+	io.WriteString(ctx.out, "\r\n\tdefault:\r\n")
+	ctx.plugin.RenderCastError(ctx.out, method, webParamName, valueExpr,
+		"fmt.Errorf(\"value '%v' is invalid\", "+valueExpr+")")
+	io.WriteString(ctx.out, "\r\n\t}")
+
+	if isArray || !required {
+		io.WriteString(ctx.out, "\r\n\t}")
+	}
+	return nil
 }
 
 func selectFunction(plugin Plugin, required, isArray bool, typeStr, in string) *Function {
@@ -674,6 +1350,58 @@ func selectFunction(plugin Plugin, required, isArray bool, typeStr, in string) *
 	return nil
 }
 
+// collectionFormat 返回数组参数的 collectionFormat, 为空时表示 multi
+func (method *Method) collectionFormat(param *Param, fields []*Field) string {
+	if len(fields) == 0 {
+		if param.option == nil {
+			return ""
+		}
+		return param.option.CollectionFormat
+	}
+	optidx := searchStructFieldParam(method.Operation,
+		GetGoVarName(param, fields[:len(fields)-1], true),
+		fields[len(fields)-1].Field)
+	if optidx < 0 {
+		return ""
+	}
+	return method.Operation.Parameters[optidx].CollectionFormat
+}
+
+// collectionSeparator 返回 collectionFormat 对应的分隔符, multi 时返回空
+func collectionSeparator(format string) string {
+	switch format {
+	case "csv":
+		return ","
+	case "ssv":
+		return " "
+	case "tsv":
+		return "\t"
+	case "pipes":
+		return "|"
+	}
+	return ""
+}
+
+// selectCollectionFunction 用读单个字符串值的函数来读取以 sep 分隔的数组, 与 strings.Fields 一样
+// 空的元素会被忽略, 生成的函数的参数名为 c, 以免与处理函数中的 r *http.Request 重名
+func selectCollectionFunction(plugin Plugin, required bool, typeStr, in, sep string) *Function {
+	if typeStr != "string" {
+		return nil
+	}
+	fn := selectFunction(plugin, required, false, "string", in)
+	if fn == nil || fn.ResultError || fn.ResultBool || fn.WithDefault {
+		return nil
+	}
+	copyed := *fn
+	copyed.IsArray = true
+	if sep == " " {
+		copyed.Format = "strings.Fields(" + fn.Format + ")"
+	} else {
+		copyed.Format = "strings.FieldsFunc(" + fn.Format + ", func(c rune) bool { return c == " + strconv.QuoteRune([]rune(sep)[0]) + " })"
+	}
+	return &copyed
+}
+
 func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fields []*Field) error {
 	io.WriteString(ctx.out, "\r\n")
 
@@ -752,18 +1480,45 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 		}
 	}
 
+	// 实现了 encoding.TextUnmarshaler 的类型用 UnmarshalText 来转换
+	isTextType := false
+	textType := typ
+	if isArray {
+		textType = elmType
+	}
+	if _, _, _, err := selectConvert(ctx.convertNS, false, "string", textType.ToLiteral()); err != nil &&
+		isTextUnmarshaler(textType) {
+		isTextType = true
+		underlying = astutil.Type{}
+		elmUnderlying = astutil.Type{}
+	}
+
+	var sep string
+	if isArray {
+		sep = collectionSeparator(method.collectionFormat(param, fields))
+	}
+
 	var fn *Function
 	if isArray {
-		if elmType.IsValid() {
-			fn = selectFunction(ctx.plugin, required, isArray, elmType.ToLiteral(), param.option.In)
-		}
-		if fn == nil && elmUnderlying.IsValid() {
-			fn = selectFunction(ctx.plugin, required, isArray, elmUnderlying.ToLiteral(), param.option.In)
+		if sep != "" {
+			if elmType.IsValid() {
+				fn = selectCollectionFunction(ctx.plugin, required, elmType.ToLiteral(), paramIn(param, fields), sep)
+			}
+			if fn == nil && elmUnderlying.IsValid() {
+				fn = selectCollectionFunction(ctx.plugin, required, elmUnderlying.ToLiteral(), paramIn(param, fields), sep)
+			}
+		} else {
+			if elmType.IsValid() {
+				fn = selectFunction(ctx.plugin, required, isArray, elmType.ToLiteral(), paramIn(param, fields))
+			}
+			if fn == nil && elmUnderlying.IsValid() {
+				fn = selectFunction(ctx.plugin, required, isArray, elmUnderlying.ToLiteral(), paramIn(param, fields))
+			}
 		}
 	} else {
-		fn = selectFunction(ctx.plugin, required, isArray, typ.ToLiteral(), param.option.In)
+		fn = selectFunction(ctx.plugin, required, isArray, typ.ToLiteral(), paramIn(param, fields))
 		if fn == nil && underlying.IsValid() {
-			fn = selectFunction(ctx.plugin, required, isArray, underlying.ToLiteral(), param.option.In)
+			fn = selectFunction(ctx.plugin, required, isArray, underlying.ToLiteral(), paramIn(param, fields))
 		}
 	}
 	if fn != nil {
@@ -798,7 +1553,7 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 
 			if underlying.IsValid() {
 				if len(fields) == 0 {
-					method.goArgumentLiterals[param.index] = typ.ToLiteral() + "(" + goVarName + ")"
+					method.goArgumentLiterals[param.index] = castToType(typ, elmUnderlying, goVarName)
 				}
 			}
 			return nil
@@ -830,14 +1585,9 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 		io.WriteString(ctx.out, goVarName+" = ")
 
 		if underlying.IsValid() {
-			io.WriteString(ctx.out, typ.ToLiteral())
-			io.WriteString(ctx.out, "(")
-		}
-
-		io.WriteString(ctx.out, valueReadText)
-
-		if underlying.IsValid() {
-			io.WriteString(ctx.out, ")")
+			io.WriteString(ctx.out, castToType(typ, elmUnderlying, valueReadText))
+		} else {
+			io.WriteString(ctx.out, valueReadText)
 		}
 
 		if isOptional {
@@ -846,7 +1596,11 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 		return nil
 	}
 
-	fn = selectFunction(ctx.plugin, required, isArray, "string", param.option.In)
+	if sep != "" {
+		fn = selectCollectionFunction(ctx.plugin, required, "string", paramIn(param, fields), sep)
+	} else {
+		fn = selectFunction(ctx.plugin, required, isArray, "string", paramIn(param, fields))
+	}
 	if fn == nil {
 		return errors.New("param '" + goVarName + "' of '" +
 			method.FullName() +
@@ -864,16 +1618,20 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 
 	convertFmt, needCast, retError, err := selectConvert(ctx.convertNS, fn.IsArray, fn.ResultType, typ.ToLiteral())
 	if err != nil {
-		originErr := err
-		if underlying.IsValid() {
-			convertFmt, needCast, retError, err = selectConvert(ctx.convertNS, fn.IsArray, fn.ResultType, underlying.ToLiteral())
-		}
-		if err != nil {
-			return errors.New("param '" + goVarName + "' of '" +
-				method.FullName() +
-				"' hasnot convert function: " + originErr.Error())
+		if isTextType {
+			convertFmt, needCast, retError = selectTextConvert(fn.IsArray, textType.ToLiteral()), false, true
+		} else {
+			originErr := err
+			if underlying.IsValid() {
+				convertFmt, needCast, retError, err = selectConvert(ctx.convertNS, fn.IsArray, fn.ResultType, underlying.ToLiteral())
+			}
+			if err != nil {
+				return errors.New("param '" + goVarName + "' of '" +
+					method.FullName() +
+					"' hasnot convert function: " + originErr.Error())
+			}
+			needCast = true
 		}
-		needCast = true
 	}
 
 	if fn.Required {
@@ -923,9 +1681,7 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 			}
 
 			io.WriteString(ctx.out, goVarName)
-			io.WriteString(ctx.out, " = "+typ.ToLiteral()+"(")
-			io.WriteString(ctx.out, fieldName(param, fields))
-			io.WriteString(ctx.out, "Value)")
+			io.WriteString(ctx.out, " = "+castToType(typ, elmUnderlying, fieldName(param, fields)+"Value"))
 		}
 		return nil
 	}
@@ -950,6 +1706,13 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 		io.WriteString(ctx.out, "; "+tmpVarName+" != \"\" {")
 	}
 
+	if fn.IsArray && len(fields) == 0 {
+		// 在转换之前检查原始值的个数, 以免转换过多的值
+		if err := method.renderMaxItemsCheck(ctx, param, tmpVarName); err != nil {
+			return err
+		}
+	}
+
 	if retError {
 		io.WriteString(ctx.out, "\r\n\t\t"+fieldName(param, fields)+"Value")
 		io.WriteString(ctx.out, ", err :="+fmt.Sprintf(convertFmt, tmpVarName))
@@ -962,7 +1725,7 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 			return err
 		}
 		if needCast {
-			io.WriteString(ctx.out, "\r\n\t\t"+goVarName+" = "+typ.ToLiteral()+"("+fieldName(param, fields)+"Value)")
+			io.WriteString(ctx.out, "\r\n\t\t"+goVarName+" = "+castToType(typ, elmUnderlying, fieldName(param, fields)+"Value"))
 		} else {
 			io.WriteString(ctx.out, "\r\n\t\t"+goVarName+" = "+fieldName(param, fields)+"Value")
 		}
@@ -972,7 +1735,7 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 		}
 
 		if needCast {
-			io.WriteString(ctx.out, "\r\n\t\t"+goVarName+" = "+typ.ToLiteral()+"("+fmt.Sprintf(convertFmt, tmpVarName)+")")
+			io.WriteString(ctx.out, "\r\n\t\t"+goVarName+" = "+castToType(typ, elmUnderlying, fmt.Sprintf(convertFmt, tmpVarName)))
 		} else {
 			io.WriteString(ctx.out, "\r\n\t\t"+goVarName+" = "+fmt.Sprintf(convertFmt, tmpVarName))
 		}
@@ -982,6 +1745,20 @@ func (method *Method) renderPrimitiveTypeParam(ctx *GenContext, param *Param, fi
 	return nil
 }
 
+// castToType 将 expr 转换为 typ 类型, slice 类型不能直接转换，需要逐个元素转换
+func castToType(typ, elmUnderlying astutil.Type, expr string) string {
+	if !typ.IsSliceType() || !elmUnderlying.IsValid() {
+		return typ.ToLiteral() + "(" + expr + ")"
+	}
+	return "func(values []" + elmUnderlying.ToLiteral() + ") " + typ.ToLiteral() + " {" +
+		"\r\n\tresults := make(" + typ.ToLiteral() + ", len(values))" +
+		"\r\n\tfor idx := range values {" +
+		"\r\n\t\tresults[idx] = " + typ.SliceElemType().ToLiteral() + "(values[idx])" +
+		"\r\n\t}" +
+		"\r\n\treturn results" +
+		"\r\n}(" + expr + ")"
+}
+
 func (method *Method) renderNullableParam(ctx *GenContext, param *Param, fields []*Field) error {
 	io.WriteString(ctx.out, "\r\n")
 
@@ -1006,7 +1783,7 @@ func (method *Method) renderNullableParam(ctx *GenContext, param *Param, fields
 			"' is unsupported type - '..." + typ.ToLiteral() + "'")
 	}
 
-	fn := selectFunction(ctx.plugin, required, isArray, ElemTypeForNullable(typ), param.option.In)
+	fn := selectFunction(ctx.plugin, required, isArray, ElemTypeForNullable(typ), paramIn(param, fields))
 	if fn != nil {
 		webParamName := GetWebParamName(param, fields)
 		var valueReadText string
@@ -1063,7 +1840,7 @@ func (method *Method) renderNullableParam(ctx *GenContext, param *Param, fields
 		return nil
 	}
 
-	fn = selectFunction(ctx.plugin, required, isArray, "string", param.option.In)
+	fn = selectFunction(ctx.plugin, required, isArray, "string", paramIn(param, fields))
 	if fn == nil {
 		return errors.New("param '" + goVarName + "' of '" +
 			method.FullName() +
@@ -1173,12 +1950,20 @@ func (method *Method) renderPtrTypeParam(ctx *GenContext, param *Param, fields [
 
 	underlying := typ.GetUnderlyingType()
 
+	// 实现了 encoding.TextUnmarshaler 的类型用 UnmarshalText 来转换
+	isTextType := false
+	if _, _, _, err := selectConvert(ctx.convertNS, false, "string", typ.ToLiteral()); err != nil &&
+		isTextUnmarshaler(typ) {
+		isTextType = true
+		underlying = astutil.Type{}
+	}
+
 	// elemTypeStr := typ.ToLiteral()
 	var fn *Function
 	if underlying.IsValid() {
-		fn = selectFunction(ctx.plugin, required, isArray, underlying.ToLiteral(), param.option.In)
+		fn = selectFunction(ctx.plugin, required, isArray, underlying.ToLiteral(), paramIn(param, fields))
 	} else {
-		fn = selectFunction(ctx.plugin, required, isArray, typ.ToLiteral(), param.option.In)
+		fn = selectFunction(ctx.plugin, required, isArray, typ.ToLiteral(), paramIn(param, fields))
 	}
 	if fn != nil {
 		webParamName := GetWebParamName(param, fields)
@@ -1231,7 +2016,7 @@ func (method *Method) renderPtrTypeParam(ctx *GenContext, param *Param, fields [
 		return nil
 	}
 
-	fn = selectFunction(ctx.plugin, required, isArray, "string", param.option.In)
+	fn = selectFunction(ctx.plugin, required, isArray, "string", paramIn(param, fields))
 	if fn == nil {
 		return errors.New("param '" + goVarName + "' of '" +
 			method.FullName() +
@@ -1249,16 +2034,20 @@ func (method *Method) renderPtrTypeParam(ctx *GenContext, param *Param, fields [
 
 	convertFmt, needCast, retError, err := selectConvert(ctx.convertNS, fn.IsArray, fn.ResultType, typ.ToLiteral())
 	if err != nil {
-		originErr := err
-		if underlying.IsValid() {
-			convertFmt, needCast, retError, err = selectConvert(ctx.convertNS, fn.IsArray, fn.ResultType, underlying.ToLiteral())
-		}
-		if err != nil {
-			return errors.New("param '" + goVarName + "' of '" +
-				method.FullName() +
-				"' hasnot convert function: " + originErr.Error())
+		if isTextType {
+			convertFmt, needCast, retError = selectTextConvert(fn.IsArray, typ.ToLiteral()), false, true
+		} else {
+			originErr := err
+			if underlying.IsValid() {
+				convertFmt, needCast, retError, err = selectConvert(ctx.convertNS, fn.IsArray, fn.ResultType, underlying.ToLiteral())
+			}
+			if err != nil {
+				return errors.New("param '" + goVarName + "' of '" +
+					method.FullName() +
+					"' hasnot convert function: " + originErr.Error())
+			}
+			needCast = true
 		}
-		needCast = true
 	}
 
 	if fn.Required {
@@ -1404,7 +2193,25 @@ func GetGoVarName(param *Param, parents []*Field, hideAnonymous ...bool) string
 	return name
 }
 
+// paramIn 返回参数的位置, struct 中带有 header 标注的字段从 header 中取值
+func paramIn(param *Param, fields []*Field) string {
+	if len(fields) > 0 && isHeaderField(fields[len(fields)-1].Field) {
+		return "header"
+	}
+	return param.option.In
+}
+
+func isHeaderField(field *astutil.Field) bool {
+	header, _ := getTagValue(field, "header")
+	return header != ""
+}
+
 func GetWebParamName(param *Param, parents []*Field) string {
+	if len(parents) > 0 {
+		if header, _ := getTagValue(parents[len(parents)-1].Field, "header"); header != "" {
+			return header
+		}
+	}
 	if param.option.In == "header" {
 		header, _ := param.option.Extensions.GetString("x-gogen-header")
 		if header != "" {
@@ -1695,8 +2502,10 @@ func (method *Method) renderMapParam(ctx *GenContext, param *Param, parents []*F
 func (method *Method) renderBodyParams(ctx *GenContext, params []BodyParam) error {
 	varName := params[0].Param.Name
 	if len(params) == 1 && isExtendEntire(params[0].Option) {
-		isString := params[0].Param.Type().IsStringType(false)
+		isString := !isGenericInstance(params[0].Param.Type()) &&
+			params[0].Param.Type().IsStringType(false)
 		isStringPtr := params[0].Param.Type().PtrElemType().IsValid() &&
+			!isGenericInstance(params[0].Param.Type().PtrElemType()) &&
 			params[0].Param.Type().PtrElemType().IsStringType(false)
 
 		if isString || isStringPtr {
@@ -1792,19 +2601,28 @@ func (method *Method) renderBodyParams(ctx *GenContext, params []BodyParam) erro
 			} else {
 				io.WriteString(ctx.out, params[idx].Param.Type().ToLiteral())
 			}
-			io.WriteString(ctx.out, "\t`json:\"")
-			if params[idx].Option.Name != "" {
-				io.WriteString(ctx.out, params[idx].Option.Name)
-			} else {
-				io.WriteString(ctx.out, toSnakeCase(params[idx].Param.Name))
+			bindName := params[idx].Option.Name
+			if bindName == "" {
+				bindName = toSnakeCase(params[idx].Param.Name)
+			}
+			io.WriteString(ctx.out, "\t`json:\""+bindName+",omitempty\"")
+			if needNegotiateBody(method) {
+				io.WriteString(ctx.out, " xml:\""+bindName+",omitempty\"")
 			}
-			io.WriteString(ctx.out, ",omitempty\"`")
+			io.WriteString(ctx.out, "`")
 
 			method.goArgumentLiterals[params[idx].Index] = "bindArgs." + fieldName
 		}
 		io.WriteString(ctx.out, "\r\n\t}")
 	}
 
+	if needNegotiateBody(method) {
+		if err := method.renderNegotiateBody(ctx, varName); err != nil {
+			return err
+		}
+		return method.renderFormDeepObjectParams(ctx, params)
+	}
+
 	io.WriteString(ctx.out, "\r\n\tif err := ")
 	io.WriteString(ctx.out, ctx.plugin.ReadBodyFunc("&"+varName))
 	io.WriteString(ctx.out, "; err != nil {\r\n")
@@ -1814,7 +2632,7 @@ func (method *Method) renderBodyParams(ctx *GenContext, params []BodyParam) erro
 	ctx.plugin.RenderBodyError(ctx.out, method, varName, "err")
 	io.WriteString(ctx.out, "\r\n\t}")
 
-	return nil
+	return method.renderFormDeepObjectParams(ctx, params)
 }
 
 func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
@@ -1825,7 +2643,26 @@ func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
 
 	io.WriteString(ctx.out, "\r\n")
 	/// 输出返回参数
-	if len(method.Method.Results.List) > 2 {
+	if method.IsWebsocket() {
+		if _, ok := eventStreamElemType(method); !ok {
+			return errors.New("websocket method '" + method.FullName() + "' must return (<-chan T, error)")
+		}
+		io.WriteString(ctx.out, "replies, err :=")
+	} else if _, ok := seqElemType(method); ok {
+		if len(method.Method.Results.List) > 1 {
+			io.WriteString(ctx.out, "items, err :=")
+		} else {
+			io.WriteString(ctx.out, "items :=")
+		}
+	} else if _, ok := eventStreamElemType(method); ok {
+		io.WriteString(ctx.out, "events, err :=")
+	} else if isStreamResult(method) {
+		if len(method.Method.Results.List) > 2 {
+			io.WriteString(ctx.out, "reader, filename, err :=")
+		} else {
+			io.WriteString(ctx.out, "reader, err :=")
+		}
+	} else if len(method.Method.Results.List) > 2 || len(responseHeaderResults(method)) > 0 {
 		for idx, result := range method.Method.Results.List {
 			if idx > 0 {
 				io.WriteString(ctx.out, ", ")
@@ -1878,14 +2715,29 @@ func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
 	noreturn := method.NoReturn()
 
 	/// 输出返回
+	if method.IsWebsocket() {
+		return method.renderWebsocket(ctx, hasResultWrap)
+	}
+	if headers := responseHeaderResults(method); len(headers) > 0 {
+		return method.renderHeaderResults(ctx, headers, hasResultWrap)
+	}
+	if elemType, ok := seqElemType(method); ok {
+		return method.renderSeqResult(ctx, elemType, hasResultWrap)
+	}
+	if yieldIdx, _ := yieldParam(method); yieldIdx >= 0 {
+		method.renderNDJSONError(ctx, hasResultWrap)
+		return method.renderNDJSONEnd(ctx)
+	}
+	if _, ok := eventStreamElemType(method); ok {
+		return method.renderEventStreamResult(ctx, hasResultWrap)
+	}
+	if isStreamResult(method) {
+		return method.renderStreamResult(ctx, hasResultWrap)
+	}
 	if len(method.Method.Results.List) > 2 {
 		io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
-		if hasResultWrap {
-			io.WriteString(ctx.out, "\r\n\tstatusCode, result := ")
-			io.WriteString(ctx.out, ctx.plugin.GetErrorResult("err"))
-			ctx.plugin.RenderReturnOK(ctx.out, method, "statusCode", "", "result")
-		} else {
-			ctx.plugin.RenderReturnError(ctx.out, method, "", "err")
+		if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+			return err
 		}
 		io.WriteString(ctx.out, "\r\n\t}")
 
@@ -1903,7 +2755,7 @@ func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
 				io.WriteString(ctx.out, result.Name)
 			}
 			io.WriteString(ctx.out, "\r\n")
-			ctx.plugin.RenderReturnOK(ctx.out, method, "", "", "result")
+			method.renderReturnOK(ctx, "", "result")
 		} else {
 			io.WriteString(ctx.out, "\r\n\tresult := map[string]interface{}{")
 			for _, result := range method.Method.Results.List {
@@ -1918,23 +2770,19 @@ func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
 				io.WriteString(ctx.out, ",")
 			}
 			io.WriteString(ctx.out, "\r\n\t}\r\n")
-			ctx.plugin.RenderReturnOK(ctx.out, method, "", "map", "result")
+			method.renderReturnOK(ctx, "map", "result")
 		}
 	} else if len(method.Method.Results.List) == 1 {
 		resultDef := method.Method.Results.List[0]
 		if resultDef.Type().IsErrorType() {
 			io.WriteString(ctx.out, "\r\nif err != nil {\r\n")
-			if hasResultWrap {
-				io.WriteString(ctx.out, "\r\n\tstatusCode, result := ")
-				io.WriteString(ctx.out, ctx.plugin.GetErrorResult("err"))
-				ctx.plugin.RenderReturnOK(ctx.out, method, "statusCode", "", "result")
-			} else {
-				ctx.plugin.RenderReturnError(ctx.out, method, "", "err")
+			if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+				return err
 			}
 			io.WriteString(ctx.out, "\r\n}")
 			io.WriteString(ctx.out, "\r\n")
 			if !noreturn {
-				ctx.plugin.RenderReturnOK(ctx.out, method, "", "string", "\"OK\"")
+				method.renderReturnOK(ctx, "string", "\"OK\"")
 			} else {
 				ctx.plugin.RenderReturnEmpty(ctx.out, method)
 			}
@@ -1949,22 +2797,18 @@ func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
 				io.WriteString(ctx.out, CamelCase(resultDef.Name))
 				io.WriteString(ctx.out, " = data")
 				io.WriteString(ctx.out, "\r\n")
-				ctx.plugin.RenderReturnOK(ctx.out, method, "", "", "result")
+				method.renderReturnOK(ctx, "", "result")
 			} else {
 				io.WriteString(ctx.out, "\r\n")
-				ctx.plugin.RenderReturnOK(ctx.out, method, "", resultDef.Type().ToLiteral(), "result")
+				method.renderReturnOK(ctx, resultDef.Type().ToLiteral(), "result")
 			}
 			// }
 		}
 
 	} else {
 		io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
-		if hasResultWrap {
-			io.WriteString(ctx.out, "\r\n\tstatusCode, result := ")
-			io.WriteString(ctx.out, ctx.plugin.GetErrorResult("err"))
-			ctx.plugin.RenderReturnOK(ctx.out, method, "statusCode", "", "result")
-		} else {
-			ctx.plugin.RenderReturnError(ctx.out, method, "", "err")
+		if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+			return err
 		}
 		io.WriteString(ctx.out, "\r\n\t}\r\n")
 
@@ -1980,12 +2824,592 @@ func (method *Method) renderInvokeAndReturn(ctx *GenContext) error {
 			io.WriteString(ctx.out, CamelCase(resultDef.Name))
 			io.WriteString(ctx.out, " = data")
 			io.WriteString(ctx.out, "\r\n")
-			ctx.plugin.RenderReturnOK(ctx.out, method, "", "", "result")
+			method.renderReturnOK(ctx, "", "result")
 		} else {
-			ctx.plugin.RenderReturnOK(ctx.out, method, "", resultDef.Type().ToLiteral(), "result")
+			method.renderReturnOK(ctx, resultDef.Type().ToLiteral(), "result")
 		}
 		// {{- end}}
 	}
 
 	return nil
 }
+
+// renderRecover 在处理函数中 recover panic, 用 recoverPanic 将它转换为带有方法名和调用栈的 error 后,
+// 和服务方法返回的错误一样返回
+func (method *Method) renderRecover(ctx *GenContext) error {
+	if ctx.recoverPanic == "" {
+		return nil
+	}
+	hasResultWrap := ctx.enableResultWrap
+	if !hasResultWrap {
+		hasResultWrap = HasResultWrap(method)
+	}
+	return ctx.plugin.RenderRecover(ctx.out, func(out io.Writer) error {
+		io.WriteString(out, "\r\n\terr := "+ctx.recoverPanic+"(\""+method.FullName()+"\", p)\r\n")
+		return method.renderResultError(ctx, hasResultWrap)
+	})
+}
+
+// renderResultError 输出 err 的返回, 启用了 result wrap 时用 GetErrorResult 包装它, 有 observer 时先调用 ServiceFailed
+func (method *Method) renderResultError(ctx *GenContext, hasResultWrap bool) error {
+	if ctx.observed {
+		renderObserveFailed(ctx.out, "ServiceFailed(err)")
+	}
+	if hasResultWrap {
+		// @x-gogen-error 匹配时用它的状态码代替 GetErrorResult 返回的状态码
+		return renderMappedErrors(ctx.out, errorMappings(method, ctx.errorMappings), "err", func(errCode string) error {
+			if errCode != "" {
+				io.WriteString(ctx.out, "\r\n\t_, result := ")
+				io.WriteString(ctx.out, ctx.plugin.GetErrorResult("err")+"\r\n")
+				return ctx.plugin.RenderReturnOK(ctx.out, method, errCode, "", "result")
+			}
+			io.WriteString(ctx.out, "\r\n\tstatusCode, result := ")
+			io.WriteString(ctx.out, ctx.plugin.GetErrorResult("err")+"\r\n")
+			return ctx.plugin.RenderReturnOK(ctx.out, method, "statusCode", "", "result")
+		})
+	}
+	return renderReturnMappedError(ctx.out, ctx.plugin, method, errorMappings(method, ctx.errorMappings), "err")
+}
+
+// renderWebsocket 将连接升级为 websocket, 把收到的 json 消息发送到 in 参数中, 并把返回的
+// channel 中的值编码为 json 发送给对方, 返回的 channel 关闭时发送 close 消息, 同时定时发送 ping
+func (method *Method) renderWebsocket(ctx *GenContext, hasResultWrap bool) error {
+	io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
+	if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+	request, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+	inIdx, elemType := websocketInParam(method)
+
+	io.WriteString(ctx.out, "\r\n\tconn, err := "+ctx.convertNS+"WebsocketUpgrader.Upgrade("+writer+", "+request+", nil)")
+	io.WriteString(ctx.out, "\r\n\tif err != nil {")
+	if inIdx >= 0 {
+		io.WriteString(ctx.out, "\r\n\t\tclose(in)")
+	}
+	io.WriteString(ctx.out, "\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t}")
+	io.WriteString(ctx.out, "\r\n\tdefer conn.Close()")
+	io.WriteString(ctx.out, "\r\n")
+	if inIdx >= 0 {
+		io.WriteString(ctx.out, "\r\n\tdone := make(chan struct{})")
+		io.WriteString(ctx.out, "\r\n\tdefer close(done)")
+	}
+	io.WriteString(ctx.out, "\r\n\tclosed := make(chan struct{})")
+	io.WriteString(ctx.out, "\r\n\tgo func() {")
+	io.WriteString(ctx.out, "\r\n\t\tdefer close(closed)")
+	if inIdx >= 0 {
+		io.WriteString(ctx.out, "\r\n\t\tdefer close(in)")
+	}
+	io.WriteString(ctx.out, "\r\n\t\tfor {")
+	if inIdx >= 0 {
+		io.WriteString(ctx.out, "\r\n\t\t\tvar msg "+elemType)
+		io.WriteString(ctx.out, "\r\n\t\t\tif err := conn.ReadJSON(&msg); err != nil {")
+		io.WriteString(ctx.out, "\r\n\t\t\t\treturn")
+		io.WriteString(ctx.out, "\r\n\t\t\t}")
+		io.WriteString(ctx.out, "\r\n\t\t\tselect {")
+		io.WriteString(ctx.out, "\r\n\t\t\tcase in <- msg:")
+		io.WriteString(ctx.out, "\r\n\t\t\tcase <-done:")
+		io.WriteString(ctx.out, "\r\n\t\t\t\treturn")
+		io.WriteString(ctx.out, "\r\n\t\t\t}")
+	} else {
+		// 没有 in 参数时也要读取连接, 否则无法处理 close, ping 和 pong 消息
+		io.WriteString(ctx.out, "\r\n\t\t\tif _, _, err := conn.NextReader(); err != nil {")
+		io.WriteString(ctx.out, "\r\n\t\t\t\treturn")
+		io.WriteString(ctx.out, "\r\n\t\t\t}")
+	}
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t}()")
+	io.WriteString(ctx.out, "\r\n")
+	io.WriteString(ctx.out, "\r\n\tticker := time.NewTicker("+durationLiteral(ctx.websocketPingPeriod)+")")
+	io.WriteString(ctx.out, "\r\n\tdefer ticker.Stop()")
+	io.WriteString(ctx.out, "\r\n\tfor {")
+	io.WriteString(ctx.out, "\r\n\t\tselect {")
+	io.WriteString(ctx.out, "\r\n\t\tcase <-closed:\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\tcase reply, ok := <-replies:")
+	io.WriteString(ctx.out, "\r\n\t\t\tif !ok {")
+	io.WriteString(ctx.out, "\r\n\t\t\t\tconn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, \"\"))\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t\tif err := conn.WriteJSON(reply); err != nil {\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\tcase <-ticker.C:")
+	io.WriteString(ctx.out, "\r\n\t\t\tif err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add("+durationLiteral(ctx.websocketWriteWait)+")); err != nil {\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
+// renderNDJSONYield 输出 yield 函数, 它将每个值编码为一行 json(application/x-ndjson) 并立即 Flush,
+// 响应头在第一个值时才输出, 这样在没有输出任何值之前出错时仍可以返回正常的错误
+func (method *Method) renderNDJSONYield(ctx *GenContext, elemType string) error {
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+
+	io.WriteString(ctx.out, "\r\n\tflusher, _ := "+writer+".(http.Flusher)")
+	io.WriteString(ctx.out, "\r\n\tencoder := json.NewEncoder("+writer+")")
+	io.WriteString(ctx.out, "\r\n\tstarted := false")
+	io.WriteString(ctx.out, "\r\n\tyield := func(item "+elemType+") error {")
+	io.WriteString(ctx.out, "\r\n\t\tif !started {")
+	io.WriteString(ctx.out, "\r\n\t\t\tstarted = true")
+	io.WriteString(ctx.out, "\r\n\t\t\t"+writer+".Header().Set(\"Content-Type\", \"application/x-ndjson\")")
+	io.WriteString(ctx.out, "\r\n\t\t\t"+writer+".Header().Set(\"Trailer\", \"X-Stream-Error\")")
+	io.WriteString(ctx.out, "\r\n\t\t\t"+writer+".WriteHeader(http.StatusOK)")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\tif err := encoder.Encode(item); err != nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\treturn err")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\tif flusher != nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\tflusher.Flush()")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\treturn nil")
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
+// renderNDJSONError 输出流中途的错误, 还没有输出值时按正常的错误返回,
+// 否则将错误放在 X-Stream-Error 这个 Trailer 中
+func (method *Method) renderNDJSONError(ctx *GenContext, hasResultWrap bool) error {
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+
+	io.WriteString(ctx.out, "\r\n\tif err != nil {")
+	io.WriteString(ctx.out, "\r\n\t\tif !started {\r\n")
+	if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t"+writer+".Header().Set(\"X-Stream-Error\", err.Error())\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
+// renderNDJSONEnd 输出 ndjson 的结束, 没有输出任何值时也要输出响应头
+func (method *Method) renderNDJSONEnd(ctx *GenContext) error {
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+
+	io.WriteString(ctx.out, "\r\n\tif !started {")
+	io.WriteString(ctx.out, "\r\n\t\t"+writer+".Header().Set(\"Content-Type\", \"application/x-ndjson\")")
+	io.WriteString(ctx.out, "\r\n\t\t"+writer+".WriteHeader(http.StatusOK)")
+	io.WriteString(ctx.out, "\r\n\t}\r\n")
+	return ctx.plugin.RenderReturnEmpty(ctx.out, method)
+}
+
+// renderSeqResult 遍历返回的 iter.Seq2, 将每个值按 ndjson 输出, @Produce 为 text/event-stream 时按 SSE 输出
+func (method *Method) renderSeqResult(ctx *GenContext, elemType string, hasResultWrap bool) error {
+	if len(method.Method.Results.List) > 1 {
+		io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
+		if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+			return err
+		}
+		io.WriteString(ctx.out, "\r\n\t}")
+	}
+	if isEventStreamProduce(method) {
+		return method.renderEventStreamSeq(ctx, hasResultWrap)
+	}
+
+	method.renderNDJSONYield(ctx, elemType)
+	io.WriteString(ctx.out, "\r\n\tfor item, err := range items {")
+	io.WriteString(ctx.out, "\r\n\t\tif err == nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\terr = yield(item)")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	method.renderNDJSONError(ctx, hasResultWrap)
+	io.WriteString(ctx.out, "\r\n\t}")
+	return method.renderNDJSONEnd(ctx)
+}
+
+// renderEventStreamSeq 遍历返回的 iter.Seq2, 每个值编码为 json 后作为一个 SSE 事件发送并立即 Flush,
+// 响应头在第一个值时才输出, 在这之前出错时按正常的错误返回, 之后出错时发送一个 error 事件, data 为错误信息
+func (method *Method) renderEventStreamSeq(ctx *GenContext, hasResultWrap bool) error {
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+
+	writeHeader := func(indent string) {
+		io.WriteString(ctx.out, "\r\n"+indent+writer+".Header().Set(\"Content-Type\", \"text/event-stream\")")
+		io.WriteString(ctx.out, "\r\n"+indent+writer+".Header().Set(\"Cache-Control\", \"no-cache\")")
+		io.WriteString(ctx.out, "\r\n"+indent+writer+".WriteHeader(http.StatusOK)")
+	}
+
+	io.WriteString(ctx.out, "\r\n\tflusher, _ := "+writer+".(http.Flusher)")
+	io.WriteString(ctx.out, "\r\n\tstarted := false")
+	io.WriteString(ctx.out, "\r\n\tfor item, err := range items {")
+	io.WriteString(ctx.out, "\r\n\t\tvar data []byte")
+	io.WriteString(ctx.out, "\r\n\t\tif err == nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\tdata, err = json.Marshal(item)")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\tif err != nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\tif !started {\r\n")
+	if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t\tfmt.Fprintf("+writer+", \"event: error\\ndata: %q\\n\\n\", err.Error())\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\tif !started {")
+	io.WriteString(ctx.out, "\r\n\t\t\tstarted = true")
+	writeHeader("\t\t\t")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\tfmt.Fprintf("+writer+", \"data: %s\\n\\n\", data)")
+	io.WriteString(ctx.out, "\r\n\t\tif flusher != nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\tflusher.Flush()")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t}")
+	io.WriteString(ctx.out, "\r\n\tif !started {")
+	writeHeader("\t\t")
+	io.WriteString(ctx.out, "\r\n\t}\r\n")
+	return ctx.plugin.RenderReturnEmpty(ctx.out, method)
+}
+
+// renderHeaderResults 将绑定到 @Header 的返回参数输出到响应头中, *http.Cookie 用 http.SetCookie 输出,
+// 其它的返回参数只有一个时直接作为 body, 有多个时和多个返回参数一样放到一个对象中
+func (method *Method) renderHeaderResults(ctx *GenContext, headers map[int]string, hasResultWrap bool) error {
+	for _, result := range method.Method.Results.List {
+		if result.Name == "" {
+			return errors.New("results of '" + method.FullName() + "' must be named when it has @Header")
+		}
+	}
+
+	io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
+	if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+	var bodyResults []astutil.Result
+	for idx, result := range method.Method.Results.List {
+		if result.Type().IsErrorType() {
+			continue
+		}
+		name, ok := headers[idx]
+		if !ok {
+			bodyResults = append(bodyResults, result)
+			continue
+		}
+
+		typ := result.Type()
+		switch {
+		case isCookieType(typ) && typ.IsPtrType():
+			io.WriteString(ctx.out, "\r\n\tif "+result.Name+" != nil {")
+			io.WriteString(ctx.out, "\r\n\t\thttp.SetCookie("+writer+", "+result.Name+")")
+			io.WriteString(ctx.out, "\r\n\t}")
+		case isCookieType(typ):
+			io.WriteString(ctx.out, "\r\n\thttp.SetCookie("+writer+", &"+result.Name+")")
+		case typ.ToLiteral() == "string":
+			io.WriteString(ctx.out, "\r\n\tif "+result.Name+" != \"\" {")
+			io.WriteString(ctx.out, "\r\n\t\t"+writer+".Header().Set(\""+name+"\", "+result.Name+")")
+			io.WriteString(ctx.out, "\r\n\t}")
+		default:
+			io.WriteString(ctx.out, "\r\n\t"+writer+".Header().Set(\""+name+"\", fmt.Sprint("+result.Name+"))")
+		}
+	}
+	io.WriteString(ctx.out, "\r\n")
+
+	if hasResultWrap {
+		io.WriteString(ctx.out, "\r\n\tresult := ")
+		io.WriteString(ctx.out, ctx.plugin.GetOkResult())
+		for _, result := range bodyResults {
+			io.WriteString(ctx.out, "\r\n\tresult.")
+			io.WriteString(ctx.out, CamelCase(result.Name))
+			io.WriteString(ctx.out, " = ")
+			io.WriteString(ctx.out, result.Name)
+		}
+		io.WriteString(ctx.out, "\r\n")
+		return method.renderReturnOK(ctx, "", "result")
+	}
+
+	switch len(bodyResults) {
+	case 0:
+		return method.renderReturnOK(ctx, "string", "\"OK\"")
+	case 1:
+		return method.renderReturnOK(ctx, bodyResults[0].Type().ToLiteral(), bodyResults[0].Name)
+	}
+	io.WriteString(ctx.out, "\r\n\tresult := map[string]interface{}{")
+	for _, result := range bodyResults {
+		io.WriteString(ctx.out, "\r\n\t\""+Underscore(result.Name)+"\":"+result.Name+",")
+	}
+	io.WriteString(ctx.out, "\r\n\t}\r\n")
+	return method.renderReturnOK(ctx, "map", "result")
+}
+
+// renderStreamResult 将返回的流直接复制到响应中, Content-Type 取 @Produce 中的第一个类型,
+// 有文件名时输出 Content-Disposition 头
+func (method *Method) renderStreamResult(ctx *GenContext, hasResultWrap bool) error {
+	io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
+	if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+
+	// 返回 (nil, nil) 时没有 body
+	io.WriteString(ctx.out, "\r\n\tif reader == nil {")
+	io.WriteString(ctx.out, "\r\n\t\t"+writer+".WriteHeader("+successStatusCode(method)+")\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t}")
+
+	if method.Method.Results.List[0].Type().ToLiteral() == "io.ReadCloser" {
+		io.WriteString(ctx.out, "\r\n\tdefer reader.Close()")
+	} else {
+		io.WriteString(ctx.out, "\r\n\tif closer, ok := reader.(io.Closer); ok {")
+		io.WriteString(ctx.out, "\r\n\t\tdefer closer.Close()")
+		io.WriteString(ctx.out, "\r\n\t}")
+	}
+
+	contentType := "application/octet-stream"
+	if len(method.Operation.Produces) > 0 {
+		contentType = method.Operation.Produces[0]
+	}
+
+	if len(method.Method.Results.List) > 2 {
+		io.WriteString(ctx.out, "\r\n\tif filename != \"\" {")
+		io.WriteString(ctx.out, "\r\n\t\t"+writer+".Header().Set(\"Content-Disposition\", mime.FormatMediaType(\"attachment\", map[string]string{\"filename\": filename}))")
+		io.WriteString(ctx.out, "\r\n\t}")
+	}
+	io.WriteString(ctx.out, "\r\n\t"+writer+".Header().Set(\"Content-Type\", \""+contentType+"\")")
+	io.WriteString(ctx.out, "\r\n\t"+writer+".Header().Set(\"Trailer\", \"X-Stream-Error\")")
+	io.WriteString(ctx.out, "\r\n\t"+writer+".WriteHeader("+successStatusCode(method)+")")
+
+	// 响应头已经输出了, 复制出错时与 ndjson 一样将错误放在 X-Stream-Error 这个 Trailer 中
+	io.WriteString(ctx.out, "\r\n\tif _, err := io.Copy("+writer+", reader); err != nil {\r\n")
+	if ctx.observed {
+		renderObserveFailed(ctx.out, "ServiceFailed(err)")
+	}
+	io.WriteString(ctx.out, "\t\t"+writer+".Header().Set(\"X-Stream-Error\", err.Error())")
+	io.WriteString(ctx.out, "\r\n\t}\r\n")
+	return ctx.plugin.RenderReturnEmpty(ctx.out, method)
+}
+
+// renderEventStreamResult 以 SSE(text/event-stream) 的方式输出 channel 中的值, 每个值编码为
+// json 后作为一个事件发送并立即 Flush, 直到 channel 关闭或请求被取消, 请求被取消时在后台
+// 读完 channel, 以免 service 中的发送者永远阻塞
+func (method *Method) renderEventStreamResult(ctx *GenContext, hasResultWrap bool) error {
+	io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
+	if err := method.renderResultError(ctx, hasResultWrap); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+
+	writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+	request, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+
+	io.WriteString(ctx.out, "\r\n\t"+writer+".Header().Set(\"Content-Type\", \"text/event-stream\")")
+	io.WriteString(ctx.out, "\r\n\t"+writer+".Header().Set(\"Cache-Control\", \"no-cache\")")
+	io.WriteString(ctx.out, "\r\n\t"+writer+".Header().Set(\"Connection\", \"keep-alive\")")
+	io.WriteString(ctx.out, "\r\n\t"+writer+".WriteHeader(http.StatusOK)")
+	io.WriteString(ctx.out, "\r\n\tflusher, _ := "+writer+".(http.Flusher)")
+	io.WriteString(ctx.out, "\r\n\tif flusher != nil {")
+	io.WriteString(ctx.out, "\r\n\t\tflusher.Flush()")
+	io.WriteString(ctx.out, "\r\n\t}")
+	io.WriteString(ctx.out, "\r\n\tfor {")
+	io.WriteString(ctx.out, "\r\n\t\tselect {")
+	io.WriteString(ctx.out, "\r\n\t\tcase <-"+request+".Context().Done():")
+	io.WriteString(ctx.out, "\r\n\t\t\tgo func() {")
+	io.WriteString(ctx.out, "\r\n\t\t\t\tfor range events {")
+	io.WriteString(ctx.out, "\r\n\t\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t\t}()\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\tcase event, ok := <-events:")
+	io.WriteString(ctx.out, "\r\n\t\t\tif !ok {\r\n")
+	ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t\tdata, err := json.Marshal(event)")
+	io.WriteString(ctx.out, "\r\n\t\t\tif err != nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\t\tfmt.Fprintf("+writer+", \"event: error\\ndata: %q\\n\\n\", err.Error())")
+	io.WriteString(ctx.out, "\r\n\t\t\t} else {")
+	io.WriteString(ctx.out, "\r\n\t\t\t\tfmt.Fprintf("+writer+", \"data: %s\\n\\n\", data)")
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t\tif flusher != nil {")
+	io.WriteString(ctx.out, "\r\n\t\t\t\tflusher.Flush()")
+	io.WriteString(ctx.out, "\r\n\t\t\t}")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
+// needNegotiateProduce 判断是否需要按 @Produce 来选择响应的编码方式,
+// 只有 json 或只有一个 text/plain 时仍由插件自己输出
+func needNegotiateProduce(method *Method) bool {
+	if method.NoReturn() {
+		return false
+	}
+	produces := method.Operation.Produces
+	if len(produces) == 1 && produces[0] == "text/plain" {
+		return false
+	}
+	for _, mimeType := range produces {
+		if mimeType != "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// renderReturnOK 输出成功的结果, @Produce 有多个类型时按 Accept 头来选择编码方式,
+// 没有匹配的类型时使用第一个类型
+func (method *Method) renderReturnOK(ctx *GenContext, dataType, data string) error {
+	if !method.NoReturn() && successStatusCode(method) == "http.StatusNoContent" {
+		// 204 时没有 body
+		writer, _ := ctx.plugin.GetSpecificTypeArgument("http.ResponseWriter")
+		io.WriteString(ctx.out, "\r\n\t"+writer+".WriteHeader(http.StatusNoContent)\r\n")
+		return ctx.plugin.RenderReturnEmpty(ctx.out, method)
+	}
+	if !needNegotiateProduce(method) {
+		return ctx.plugin.RenderReturnOK(ctx.out, method, "", dataType, data)
+	}
+
+	produces := method.Operation.Produces
+	if len(produces) == 1 {
+		return method.renderEncodeResult(ctx, produces[0], dataType, data)
+	}
+
+	request, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+	var conds []string
+	for _, mimeType := range produces {
+		conds = append(conds, "mediaType == \""+mimeType+"\"")
+	}
+
+	io.WriteString(ctx.out, "\r\n\tresponseType := \""+produces[0]+"\"")
+	io.WriteString(ctx.out, "\r\n\tfor _, accepted := range strings.Split("+request+".Header.Get(\"Accept\"), \",\") {")
+	io.WriteString(ctx.out, "\r\n\t\tif mediaType, _, err := mime.ParseMediaType(accepted); err == nil && ("+strings.Join(conds, " || ")+") {")
+	io.WriteString(ctx.out, "\r\n\t\t\tresponseType = mediaType")
+	io.WriteString(ctx.out, "\r\n\t\t\tbreak")
+	io.WriteString(ctx.out, "\r\n\t\t}")
+	io.WriteString(ctx.out, "\r\n\t}")
+	io.WriteString(ctx.out, "\r\n\tswitch responseType {")
+	for _, mimeType := range produces[1:] {
+		io.WriteString(ctx.out, "\r\n\tcase \""+mimeType+"\":\r\n")
+		if err := method.renderEncodeResult(ctx, mimeType, dataType, data); err != nil {
+			return err
+		}
+	}
+	io.WriteString(ctx.out, "\r\n\tdefault:\r\n")
+	if err := method.renderEncodeResult(ctx, produces[0], dataType, data); err != nil {
+		return err
+	}
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
+
+// renderEncodeResult 按指定的类型编码并输出结果, json 由插件输出, xml 由 encoding/xml 编码,
+// csv 由 csvutil 编码, 字符串和 []byte 直接输出, 其它类型由 EncodeBody 编码
+func (method *Method) renderEncodeResult(ctx *GenContext, mimeType, dataType, data string) error {
+	if mimeType == "application/json" {
+		return ctx.plugin.RenderReturnOK(ctx.out, method, "", dataType, data)
+	}
+
+	if mimeType == "text/plain" ||
+		(mimeType == "application/octet-stream" && (dataType == "string" || dataType == "[]byte")) {
+		switch dataType {
+		case "[]byte":
+			io.WriteString(ctx.out, "\r\n\tencoded := "+data)
+		case "string":
+			io.WriteString(ctx.out, "\r\n\tencoded := []byte("+data+")")
+		default:
+			io.WriteString(ctx.out, "\r\n\tencoded := []byte(fmt.Sprint("+data+"))")
+		}
+	} else {
+		var encode string
+		switch {
+		case isExceptedType(mimeType, xmlBodyMimeTypes):
+			encode = "xml.Marshal(" + data + ")"
+		case mimeType == "text/csv":
+			encode = "csvutil.Marshal(" + data + ")"
+		default:
+			encode = ctx.convertNS + "EncodeBody(\"" + mimeType + "\", " + data + ")"
+		}
+		io.WriteString(ctx.out, "\r\n\tencoded, err := "+encode)
+		io.WriteString(ctx.out, "\r\n\tif err != nil {\r\n")
+		// 编码出错时与方法返回的错误一样处理, 这样 observer, 错误映射和 result wrap 都有效
+		if err := method.renderResultError(ctx, ctx.enableResultWrap || HasResultWrap(method)); err != nil {
+			return err
+		}
+		io.WriteString(ctx.out, "\r\n\t}")
+	}
+
+	contentType := mimeType
+	if strings.HasPrefix(mimeType, "text/") || isExceptedType(mimeType, xmlBodyMimeTypes) {
+		contentType = mimeType + "; charset=utf-8"
+	}
+	return ctx.plugin.RenderReturnBlob(ctx.out, method, successStatusCode(method), contentType, "encoded")
+}
+
+// 这些类型的 body 由框架自带的 ReadBodyFunc 来读取
+var defaultBodyMimeTypes = []string{
+	"application/json",
+	"multipart/form-data",
+	"application/x-www-form-urlencoded",
+}
+
+var xmlBodyMimeTypes = []string{
+	"application/xml",
+	"text/xml",
+}
+
+// needNegotiateBody 判断是否需要按 Content-Type 来选择 body 的解码方式
+func needNegotiateBody(method *Method) bool {
+	for _, mimeType := range method.Operation.Consumes {
+		if !isExceptedType(mimeType, defaultBodyMimeTypes) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderNegotiateBody 按 Content-Type 从 @Accept 列出的类型中选择 body 的解码方式,
+// json 和表单由 ReadBodyFunc 读取, xml 由 encoding/xml 读取, 其它类型由 DecodeBody 读取，
+// 不支持的类型返回 415
+func (method *Method) renderNegotiateBody(ctx *GenContext, varName string) error {
+	var defaultTypes, xmlTypes, otherTypes []string
+	for idx, mimeType := range method.Operation.Consumes {
+		var quoted = "\"" + mimeType + "\""
+		if idx == 0 {
+			// 没有 Content-Type 时按第一个类型来读取
+			quoted = "\"\", " + quoted
+		}
+		if isExceptedType(mimeType, defaultBodyMimeTypes) {
+			defaultTypes = append(defaultTypes, quoted)
+		} else if isExceptedType(mimeType, xmlBodyMimeTypes) {
+			xmlTypes = append(xmlTypes, quoted)
+		} else {
+			otherTypes = append(otherTypes, quoted)
+		}
+	}
+
+	request, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+	reader, _ := ctx.plugin.GetSpecificTypeArgument("io.Reader")
+
+	io.WriteString(ctx.out, "\r\n\tmediaType, _, _ := mime.ParseMediaType("+request+".Header.Get(\"Content-Type\"))")
+	io.WriteString(ctx.out, "\r\n\tswitch mediaType {")
+	if len(defaultTypes) > 0 {
+		io.WriteString(ctx.out, "\r\n\tcase "+strings.Join(defaultTypes, ", ")+":")
+		io.WriteString(ctx.out, "\r\n\t\tif err := "+ctx.plugin.ReadBodyFunc("&"+varName)+"; err != nil {\r\n")
+		ctx.plugin.RenderBodyError(ctx.out, method, varName, "err")
+		io.WriteString(ctx.out, "\r\n\t\t}")
+	}
+	if len(xmlTypes) > 0 {
+		io.WriteString(ctx.out, "\r\n\tcase "+strings.Join(xmlTypes, ", ")+":")
+		io.WriteString(ctx.out, "\r\n\t\tif err := xml.NewDecoder("+reader+").Decode(&"+varName+"); err != nil {\r\n")
+		ctx.plugin.RenderBodyError(ctx.out, method, varName, "err")
+		io.WriteString(ctx.out, "\r\n\t\t}")
+	}
+	if len(otherTypes) > 0 {
+		io.WriteString(ctx.out, "\r\n\tcase "+strings.Join(otherTypes, ", ")+":")
+		io.WriteString(ctx.out, "\r\n\t\tif err := "+ctx.convertNS+"DecodeBody(mediaType, "+reader+", &"+varName+"); err != nil {\r\n")
+		ctx.plugin.RenderBodyError(ctx.out, method, varName, "err")
+		io.WriteString(ctx.out, "\r\n\t\t}")
+	}
+	io.WriteString(ctx.out, "\r\n\tdefault:\r\n")
+	// 与其它参数绑定错误一样用 NewBadArgument 包装它, 只是状态码为 415
+	renderReturnRejected(ctx, method, "Content-Type", "http.StatusUnsupportedMediaType",
+		getBodyErrorText(ctx.badArgument, method, varName, "fmt.Errorf(\"unsupported media type '%s'\", mediaType)"), true)
+	io.WriteString(ctx.out, "\r\n\t}")
+	return nil
+}
@@ -0,0 +1,68 @@
+package gengen
+
+import (
+	"go/token"
+	"io"
+	"strings"
+)
+
+// observationTypeName 返回 Observation 的类型名, 它与 Observer 在同一个包中
+func observationTypeName(observer string) string {
+	if idx := strings.LastIndex(observer, "."); idx >= 0 {
+		return observer[:idx+1] + "Observation"
+	}
+	return "Observation"
+}
+
+// renderObserveStart 在处理函数的开头调用 observer.Start, 并在处理函数返回时调用 observation.Written,
+// route 是路由路径的表达式, observer 为 nil 时 observation 也为 nil, 后面的 BindFailed 和 ServiceFailed 都不会被调用
+func renderObserveStart(out io.Writer, plugin Plugin, cfg *Config, method *Method, route string) error {
+	stdctx, _ := plugin.GetSpecificTypeArgument("context.Context")
+
+	io.WriteString(out, "\r\n\tvar observation "+observationTypeName(cfg.Observer))
+	io.WriteString(out, "\r\n\tif observer != nil {")
+	io.WriteString(out, "\r\n\tvar observedCtx context.Context")
+	io.WriteString(out, "\r\n\tobservedCtx, observation = observer.Start("+stdctx+", \""+method.FullName()+"\", "+route+")")
+	if err := plugin.RenderSetContext(out, "observedCtx"); err != nil {
+		return err
+	}
+	if err := plugin.RenderDeferWritten(out, "observation"); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\t}")
+	return err
+}
+
+// renderObserveFailed 输出调用 observation 的 BindFailed 或 ServiceFailed 的代码
+func renderObserveFailed(out io.Writer, call string) {
+	io.WriteString(out, "\tif observation != nil {")
+	io.WriteString(out, "\r\n\t\tobservation."+call)
+	io.WriteString(out, "\r\n\t}\r\n")
+}
+
+// observedPlugin 在参数绑定失败时先调用 observation.BindFailed 再返回错误
+type observedPlugin struct {
+	Plugin
+}
+
+func (p observedPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
+	renderObserveFailed(out, "BindFailed(\""+bodyName+"\", "+err+")")
+	return p.Plugin.RenderBodyError(out, method, bodyName, err)
+}
+
+func (p observedPlugin) RenderCastError(out io.Writer, method *Method, accessFields, value, err string) error {
+	renderObserveFailed(out, "BindFailed(\""+accessFields+"\", "+err+")")
+	return p.Plugin.RenderCastError(out, method, accessFields, value, err)
+}
+
+// renderReturnRejected 输出拒绝请求(如认证失败)时的返回, 有 observer 时先调用 observation.BindFailed
+func renderReturnRejected(ctx *GenContext, method *Method, param, errCode, err string, errwrapped ...bool) error {
+	if ctx.observed {
+		if !token.IsIdentifier(err) {
+			io.WriteString(ctx.out, "\terr := "+err+"\r\n")
+			err = "err"
+		}
+		renderObserveFailed(ctx.out, "BindFailed(\""+param+"\", "+err+")")
+	}
+	return ctx.plugin.RenderReturnError(ctx.out, method, errCode, err, errwrapped...)
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -19,6 +21,10 @@ type Config struct {
 	OkResult         string
 	EnableResultWrap bool
 	CustomReturnFunc string
+	WriteProblem     string
+	RouteInfo        string
+	Observer         string
+	RecoverPanic     string
 }
 
 type Function struct {
@@ -41,15 +47,15 @@ func createPlugin(plugin string, cfg Config) (Plugin, error) {
 		echo := &echoPlugin{cfg: cfg}
 		version := os.Getenv("GOGEN_ECHO_VERSION")
 		if version == "v5" {
-			echo.isV5  = true
+			echo.isV5 = true
 		}
-		
+
 		if cfg.CustomReturnFunc != "" {
 			echo.initCustomReturnFunc(cfg.CustomReturnFunc)
 		}
 		return echo, nil
 	case "echov5":
-		echo := &echoPlugin{cfg: cfg,isV5: true}
+		echo := &echoPlugin{cfg: cfg, isV5: true}
 		if cfg.CustomReturnFunc != "" {
 			echo.initCustomReturnFunc(cfg.CustomReturnFunc)
 		}
@@ -59,7 +65,7 @@ func createPlugin(plugin string, cfg Config) (Plugin, error) {
 	case "loong":
 		version := os.Getenv("GOGEN_ECHO_VERSION")
 		if version == "v5" {
-			echo := &echoPlugin{cfg: cfg,isV5: true}
+			echo := &echoPlugin{cfg: cfg, isV5: true}
 			if cfg.CustomReturnFunc != "" {
 				echo.initCustomReturnFunc(cfg.CustomReturnFunc)
 			}
@@ -82,9 +88,16 @@ type Plugin interface {
 	HeaderFunctions() []Function
 
 	ReadBodyFunc(argName string) string
-	RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, fn func(io.Writer) error) error
+	// RenderFunc 输出注册路由的代码, middlewares 是只作用于这个路由的中间件, handler 输出处理函数
+	RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, middlewares []string, handler func(io.Writer) error) error
+	// HandlerTypeName 返回处理函数的类型名
+	HandlerTypeName() string
+	// RenderHandlerFunc 输出处理函数的函数字面量, fn 输出它的函数体
+	RenderHandlerFunc(out io.Writer, method *Method, fn func(io.Writer) error) error
 	RenderReturnOK(out io.Writer, method *Method, statusCode, dataType, data string) error
 	RenderReturnEmpty(out io.Writer, method *Method) error
+	// RenderReturnBlob 输出用 contentType 返回已编码的 data 的代码
+	RenderReturnBlob(out io.Writer, method *Method, statusCode, contentType, data string) error
 	RenderReturnError(out io.Writer, method *Method, errCode, err string, errwrapped ...bool) error
 
 	RenderBodyError(out io.Writer, method *Method, accessFields, err string) error
@@ -93,12 +106,73 @@ type Plugin interface {
 	GetErrorResult(err string) string
 	GetOkResult() string
 
-
 	MiddlewaresDeclaration() string
+	// MiddlewareTypeName 返回中间件的类型名
+	MiddlewareTypeName() string
+	// NamedMiddlewaresDeclaration 返回 @x-gogen-middleware 使用的中间件集合的参数声明
+	NamedMiddlewaresDeclaration() string
 	RenderWithMiddlewares(mux string) string
+	// RenderSetContext 输出替换请求的 context 的代码
+	RenderSetContext(out io.Writer, stdctx string) error
+	// RenderDeferWritten 输出在处理函数返回时用响应的状态码调用 observation.Written 的代码
+	RenderDeferWritten(out io.Writer, observation string) error
+	// RenderRecover 输出 recover panic 的代码, fn 输出 p 不为 nil 时返回错误的代码
+	RenderRecover(out io.Writer, fn func(io.Writer) error) error
+	// RenderMiddlewareFunc 输出一个中间件的函数字面量, fn 输出它在调用下一个处理函数之前执行的代码,
+	// 这些代码中可以使用与处理函数中相同的变量名
+	RenderMiddlewareFunc(out io.Writer, fn func(io.Writer) error) error
 	// RenderMiddlewares(out io.Writer, fn func(out io.Writer) error) error
 }
 
+// namedMiddlewares 返回方法用 @x-gogen-middleware 声明的中间件, 如 middlewares["audit"], middlewares["rateLimit"]
+func namedMiddlewares(method *Method) []string {
+	var middlewares []string
+	for _, name := range method.middlewares {
+		middlewares = append(middlewares, "middlewares["+strconv.Quote(name)+"]")
+	}
+	return middlewares
+}
+
+// renderRepanicAbort 输出重新抛出 http.ErrAbortHandler 的代码, 它是用来中止响应的, 不能被当作错误返回
+func renderRepanicAbort(out io.Writer) {
+	io.WriteString(out, "\r\n\tif p == http.ErrAbortHandler {")
+	io.WriteString(out, "\r\n\t\tpanic(p)")
+	io.WriteString(out, "\r\n\t}")
+}
+
+// renderRecover 输出 recover panic 的代码, 用于处理函数没有返回值的框架
+func renderRecover(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "\r\n\tdefer func() {")
+	io.WriteString(out, "\r\n\tif p := recover(); p != nil {")
+	renderRepanicAbort(out)
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\t}\r\n\t}()")
+	return err
+}
+
+// renderRecoverWithError 输出 recover panic 的代码, 用于处理函数返回 error 的框架,
+// 这时处理函数的返回值被命名为 handlerErr
+func renderRecoverWithError(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "\r\n\tdefer func() {")
+	io.WriteString(out, "\r\n\tif p := recover(); p != nil {")
+	renderRepanicAbort(out)
+	io.WriteString(out, "\r\n\thandlerErr = func() error {")
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\t}()\r\n\t}\r\n\t}()")
+	return err
+}
+
+// parseMiddlewares 解析 "audit,rateLimit" 这样的值
+func parseMiddlewares(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
 func getBodyErrorText(badArg string, method *Method, bodyName, err string) string {
 	txt := badArg + "(" + err + ", \"" + method.FullName() + "\", \"" + bodyName + "\")"
 	// return "fmt.Errorf(\"argument %q is invalid - %q\", \""+bodyName+"\", \"body\", "+ err + ")"
@@ -139,3 +213,208 @@ func statusCodeLiteralByMethod(op string) string {
 	}
 	return "http.StatusOK"
 }
+
+var statusCodeLiterals = map[int]string{
+	200: "http.StatusOK",
+	201: "http.StatusCreated",
+	202: "http.StatusAccepted",
+	203: "http.StatusNonAuthoritativeInfo",
+	204: "http.StatusNoContent",
+	205: "http.StatusResetContent",
+	206: "http.StatusPartialContent",
+
+	400: "http.StatusBadRequest",
+	401: "http.StatusUnauthorized",
+	403: "http.StatusForbidden",
+	404: "http.StatusNotFound",
+	405: "http.StatusMethodNotAllowed",
+	406: "http.StatusNotAcceptable",
+	408: "http.StatusRequestTimeout",
+	409: "http.StatusConflict",
+	410: "http.StatusGone",
+	412: "http.StatusPreconditionFailed",
+	413: "http.StatusRequestEntityTooLarge",
+	415: "http.StatusUnsupportedMediaType",
+	422: "http.StatusUnprocessableEntity",
+	429: "http.StatusTooManyRequests",
+	500: "http.StatusInternalServerError",
+	501: "http.StatusNotImplemented",
+	502: "http.StatusBadGateway",
+	503: "http.StatusServiceUnavailable",
+	504: "http.StatusGatewayTimeout",
+}
+
+func statusCodeLiteral(code int) string {
+	if s, ok := statusCodeLiterals[code]; ok {
+		return s
+	}
+	return strconv.Itoa(code)
+}
+
+// successStatusCodes 返回 @Success 中列出的 2xx 状态码, 从小到大排序
+func successStatusCodes(method *Method) []int {
+	if method.Operation.Responses == nil {
+		return nil
+	}
+	var codes []int
+	for code := range method.Operation.Responses.StatusCodeResponses {
+		if code >= 200 && code < 300 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// successStatusCode 返回成功时的状态码, x-gogen-status-code 优先, 其次是 @Success 中最小的 2xx 状态码,
+// 都没有时 POST 为 201, 其它为 200(按正在输出的路由的 HTTP 方法)
+func successStatusCode(method *Method) string {
+	if withCode := WithCode(method); withCode != "" {
+		return withCode
+	}
+	if codes := successStatusCodes(method); len(codes) > 0 {
+		return statusCodeLiteral(codes[0])
+	}
+	return statusCodeLiteralByMethod(method.routeHTTPMethod())
+}
+
+// declaredStatusCode 返回 x-gogen-status-code 或 @Success 中声明的成功状态码, 为 200 或没有声明时返回空,
+// 用于默认就返回 200 的框架(如 chi 和 iris)
+func declaredStatusCode(method *Method) string {
+	code := WithCode(method)
+	if code == "" {
+		if codes := successStatusCodes(method); len(codes) > 0 {
+			code = statusCodeLiteral(codes[0])
+		}
+	}
+	if code == "http.StatusOK" || code == "200" {
+		return ""
+	}
+	return code
+}
+
+// ErrorMapping 是 error 到状态码的映射, Error 以 * 开头时表示一个错误类型(用 errors.As 判断),
+// 否则是一个错误变量(用 errors.Is 判断)
+type ErrorMapping struct {
+	Error      string
+	StatusCode int
+}
+
+func (m ErrorMapping) IsType() bool {
+	return strings.HasPrefix(m.Error, "*")
+}
+
+// Match 返回判断 err 是否匹配的表达式
+func (m ErrorMapping) Match(err string) string {
+	if m.IsType() {
+		return "errors.As(" + err + ", new(" + m.Error + "))"
+	}
+	return "errors.Is(" + err + ", " + m.Error + ")"
+}
+
+// parseErrorMapping 解析 "ErrNotFound 404" 或 "ErrNotFound=404" 格式的映射
+func parseErrorMapping(s string) (ErrorMapping, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '=' || r == ' ' || r == '\t'
+	})
+	if len(fields) != 2 {
+		return ErrorMapping{}, errors.New("error mapping '" + s + "' is invalid, it must be '<error> <status code>'")
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil || code < 100 || code > 599 {
+		return ErrorMapping{}, errors.New("error mapping '" + s + "' is invalid, status code '" + fields[1] + "' is invalid")
+	}
+	return ErrorMapping{Error: fields[0], StatusCode: code}, nil
+}
+
+// ParseErrorMappings 解析以逗号分隔的多个映射, 如 "ErrNotFound=404,*ValidationError=422"
+func ParseErrorMappings(s string) ([]ErrorMapping, error) {
+	var mappings []ErrorMapping
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		mapping, err := parseErrorMapping(item)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// errorMappings 返回方法上 @x-gogen-error 声明的映射, 后面跟着命令行中指定的映射
+func errorMappings(method *Method, defaults []ErrorMapping) []ErrorMapping {
+	if len(defaults) == 0 {
+		return method.errorMappings
+	}
+	mappings := append([]ErrorMapping{}, method.errorMappings...)
+	for _, mapping := range defaults {
+		found := false
+		for _, m := range method.errorMappings {
+			if m.Error == mapping.Error {
+				found = true
+				break
+			}
+		}
+		if !found {
+			mappings = append(mappings, mapping)
+		}
+	}
+	return mappings
+}
+
+// renderWriteBlob 直接用 http.ResponseWriter 输出已编码的 data, 用于没有相应方法的框架
+func renderWriteBlob(out io.Writer, plugin Plugin, method *Method, statusCode, contentType, data string) error {
+	writer, _ := plugin.GetSpecificTypeArgument("http.ResponseWriter")
+	io.WriteString(out, "\r\n\t"+writer+".Header().Set(\"Content-Type\", \""+contentType+"\")")
+	io.WriteString(out, "\r\n\t"+writer+".WriteHeader("+statusCode+")")
+	io.WriteString(out, "\r\n\t"+writer+".Write("+data+")\r\n")
+	return plugin.RenderReturnEmpty(out, method)
+}
+
+// renderReturnMappedError 先按 errorMappings 用 errors.Is/errors.As 判断 err, 匹配时返回对应的状态码,
+// 都不匹配时再交给 httpCodeWith
+func renderReturnMappedError(out io.Writer, plugin Plugin, method *Method, mappings []ErrorMapping, err string) error {
+	return renderMappedErrors(out, mappings, err, func(errCode string) error {
+		return plugin.RenderReturnError(out, method, errCode, err)
+	})
+}
+
+// renderMappedErrors 按 errorMappings 输出判断 err 的 switch 语句, 匹配时用对应的状态码调用 fn,
+// 都不匹配时用空的状态码调用 fn
+func renderMappedErrors(out io.Writer, mappings []ErrorMapping, err string, fn func(errCode string) error) error {
+	if len(mappings) > 0 {
+		io.WriteString(out, "\tswitch {")
+		for _, mapping := range mappings {
+			io.WriteString(out, "\r\n\tcase "+mapping.Match(err)+":\r\n")
+			if e := fn(statusCodeLiteral(mapping.StatusCode)); e != nil {
+				return e
+			}
+		}
+		io.WriteString(out, "\r\n\t}\r\n")
+	}
+	return fn("")
+}
+
+// renderReturnProblem 调用 WriteProblem 以 application/problem+json 格式(RFC 7807)返回错误,
+// param 为出错的参数名, 没有时为空
+func renderReturnProblem(out io.Writer, plugin Plugin, cfg *Config, method *Method, errCode, err, param string) error {
+	if errCode == "" {
+		if cfg.HttpCodeWith != "" {
+			errCode = cfg.HttpCodeWith + "(" + err + ")"
+		} else {
+			errCode = "http.StatusInternalServerError"
+		}
+	}
+	w, _ := plugin.GetSpecificTypeArgument("http.ResponseWriter")
+	r, _ := plugin.GetSpecificTypeArgument("*http.Request")
+
+	io.WriteString(out, cfg.WriteProblem+"("+w+", "+r+", "+errCode+", \""+method.FullName()+"\", "+err)
+	if param != "" {
+		io.WriteString(out, ", \""+param+"\"")
+	}
+	io.WriteString(out, ")\r\n")
+	return plugin.RenderReturnEmpty(out, method)
+}
@@ -3,6 +3,7 @@ package gengen
 import (
 	"io"
 	"os"
+	"strings"
 
 	"github.com/swaggo/swag"
 )
@@ -18,12 +19,11 @@ type chiPlugin struct {
 	cfg Config
 }
 
-
 func (chi *chiPlugin) HeaderFunctions() []Function {
 	return []Function{
 		{
 			Required:    true,
-			Format:      "r.Header.Get(r, \"%s\")",
+			Format:      "r.Header.Get(\"%s\")",
 			IsArray:     false,
 			ResultType:  "string",
 			ResultError: false,
@@ -39,7 +39,7 @@ func (chi *chiPlugin) HeaderFunctions() []Function {
 		},
 		{
 			Required:    false,
-			Format:      "r.Header[\"%s\"]",
+			Format:      "r.Header.Values(\"%s\")",
 			IsArray:     true,
 			ResultType:  "string",
 			ResultError: false,
@@ -48,7 +48,6 @@ func (chi *chiPlugin) HeaderFunctions() []Function {
 	}
 }
 
-
 func (chi *chiPlugin) Functions() []Function {
 	return []Function{
 		{
@@ -79,13 +78,16 @@ func (chi *chiPlugin) Functions() []Function {
 }
 
 func (chi *chiPlugin) Imports() map[string]string {
-	return map[string]string{
+	imports := map[string]string{
 		"github.com/go-chi/chi":    "",
 		"github.com/go-chi/render": "",
 	}
+	if chi.cfg.Observer != "" {
+		imports["github.com/go-chi/chi/middleware"] = ""
+	}
+	return imports
 }
 
-
 func (chi *chiPlugin) PartyTypeName() string {
 	return "chi.Router"
 }
@@ -122,13 +124,47 @@ func (chi *chiPlugin) GetSpecificTypeArgument(typeStr string) (string, bool) {
 // 	return getCastErrorText(chi.cfg.NewBadArgument, method, accessFields, err, value)
 // }
 
-
 func (chi *chiPlugin) MiddlewaresDeclaration() string {
 	return "handlers ...func(http.Handler) http.Handler"
 }
 
+func (chi *chiPlugin) MiddlewareTypeName() string {
+	return "func(http.Handler) http.Handler"
+}
+
+func (chi *chiPlugin) RenderMiddlewareFunc(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "func(next http.Handler) http.Handler {")
+	io.WriteString(out, "\r\n\treturn http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {")
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\tnext.ServeHTTP(w, r)\r\n\t})\r\n}")
+	return err
+}
+
+func (chi *chiPlugin) NamedMiddlewaresDeclaration() string {
+	return "middlewares map[string]" + chi.MiddlewareTypeName()
+}
+
 func (chi *chiPlugin) RenderWithMiddlewares(mux string) string {
-	return mux + " = "+mux+".With(handlers...)"
+	return mux + " = " + mux + ".With(handlers...)"
+}
+
+func (chi *chiPlugin) RenderSetContext(out io.Writer, stdctx string) error {
+	_, err := io.WriteString(out, "\r\n\tr = r.WithContext("+stdctx+")")
+	return err
+}
+
+// RenderDeferWritten 中 chi 不能直接取得状态码, 需要用 middleware.WrapResponseWriter 包装 w
+func (chi *chiPlugin) RenderDeferWritten(out io.Writer, observation string) error {
+	_, err := io.WriteString(out, "\r\n\tww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)"+
+		"\r\n\tw = ww"+
+		"\r\n\tdefer func() { "+observation+".Written(ww.Status()) }()")
+	return err
+}
+
+func (chi *chiPlugin) RenderRecover(out io.Writer, fn func(io.Writer) error) error {
+	return renderRecover(out, fn)
 }
 
 func (chi *chiPlugin) RenderMiddlewares(out io.Writer, fn func(out io.Writer) error) error {
@@ -139,7 +175,7 @@ func (chi *chiPlugin) ReadBodyFunc(argName string) string {
 	return "render.Decode(r, " + argName + ")"
 }
 
-func (chi *chiPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, fn func(out io.Writer) error) error {
+func (chi *chiPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, middlewares []string, handler func(out io.Writer) error) error {
 	urlstr, err := ConvertURL(route.Path, false, Colon)
 	if err != nil {
 		return err
@@ -148,7 +184,27 @@ func (chi *chiPlugin) RenderFunc(out io.Writer, method *Method, route swag.Route
 	// 	urlstr = ""
 	// }
 
-	_, err = io.WriteString(out, "\r\nmux."+ConvertMethodNameToCamelCase(route.HTTPMethod)+"(\""+urlstr+"\", func(w http.ResponseWriter, r *http.Request) {")
+	mux := "mux"
+	if len(middlewares) > 0 {
+		mux += ".With(" + strings.Join(middlewares, ", ") + ")"
+	}
+	_, err = io.WriteString(out, "\r\n"+mux+"."+ConvertMethodNameToCamelCase(route.HTTPMethod)+"(\""+urlstr+"\", ")
+	if err != nil {
+		return err
+	}
+	if err := handler(out); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, ")")
+	return err
+}
+
+func (chi *chiPlugin) HandlerTypeName() string {
+	return "http.HandlerFunc"
+}
+
+func (chi *chiPlugin) RenderHandlerFunc(out io.Writer, method *Method, fn func(out io.Writer) error) error {
+	_, err := io.WriteString(out, "func(w http.ResponseWriter, r *http.Request) {")
 	if err != nil {
 		return err
 	}
@@ -164,7 +220,7 @@ func (chi *chiPlugin) RenderFunc(out io.Writer, method *Method, route swag.Route
 		return err
 	}
 
-	_, err = io.WriteString(out, "\r\n})")
+	_, err = io.WriteString(out, "\r\n}")
 	return err
 }
 
@@ -189,6 +245,8 @@ func (chi *chiPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode,
 	}
 	if statusCode != "" {
 		args["statusCode"] = statusCode
+	} else if code := declaredStatusCode(method); code != "" {
+		args["statusCode"] = code
 	}
 
 	renderFunc := "JSON"
@@ -202,7 +260,7 @@ func (chi *chiPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode,
 {{- else -}}
   {{- if .statusCode -}}
 		render.Status(r, {{.statusCode}})
-  {{- end -}}
+  {{end -}}
 	render.`+renderFunc+`(w, r, {{.data}})
   return
 {{- end}}`, args)
@@ -212,15 +270,25 @@ func (chi *chiPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode,
 
 func (chi *chiPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
 	txt := getBodyErrorText(chi.cfg.NewBadArgument, method, bodyName, err)
+	if chi.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, chi, &chi.cfg, method, "http.StatusBadRequest", txt, bodyName)
+	}
 	return chi.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (chi *chiPlugin) RenderCastError(out io.Writer, method *Method, accessFields, value, err string) error {
 	txt := getCastErrorText(chi.cfg.NewBadArgument, method, accessFields, err, value)
+	if chi.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, chi, &chi.cfg, method, "http.StatusBadRequest", txt, accessFields)
+	}
 	return chi.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (chi *chiPlugin) RenderReturnError(out io.Writer, method *Method, errCode, err string, errwrapped ...bool) error {
+	if chi.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, chi, &chi.cfg, method, errCode, err, "")
+	}
+
 	if errCode == "" && chi.cfg.HttpCodeWith != "" {
 		errCode = chi.cfg.HttpCodeWith + "(" + err + ")"
 	}
@@ -249,6 +317,10 @@ func (chi *chiPlugin) RenderReturnError(out io.Writer, method *Method, errCode,
 	return e
 }
 
+func (chi *chiPlugin) RenderReturnBlob(out io.Writer, method *Method, statusCode, contentType, data string) error {
+	return renderWriteBlob(out, chi, method, statusCode, contentType, data)
+}
+
 func (chi *chiPlugin) RenderReturnEmpty(out io.Writer, method *Method) error {
 	_, e := io.WriteString(out, "return")
 	return e
@@ -11,16 +11,16 @@ import (
 var _ Plugin = &echoPlugin{}
 
 type echoPlugin struct {
-	cfg Config
+	cfg  Config
 	isV5 bool
 
-	customReturnFunc bool
-	returnResultFuncName string
+	customReturnFunc            bool
+	returnResultFuncName        string
 	returnCreatedResultFuncName string
-	returnUpdatedResultFuncName  string
+	returnUpdatedResultFuncName string
 	returnDeletedResultFuncName string
-	returnQueryResultFuncName string
-	returnErrorResultFuncName string
+	returnQueryResultFuncName   string
+	returnErrorResultFuncName   string
 }
 
 func (echo *echoPlugin) initCustomReturnFunc(ns string) {
@@ -42,19 +42,19 @@ func (echo *echoPlugin) GetSpecificTypeArgument(typeStr string) (string, bool) {
 	}
 
 	args := map[string]string{
-		"url.Values":          "ctx.QueryParams()",
-		"*http.Request":       "ctx.Request()",
-		"io.Reader":           "ctx.Request().Body",
+		"url.Values":    "ctx.QueryParams()",
+		"*http.Request": "ctx.Request()",
+		"io.Reader":     "ctx.Request().Body",
 
 		"http.ResponseWriter": "ctx.Response().Writer",
 		"io.Writer":           "ctx.Response().Writer",
 		"context.Context":     "ctx.Request().Context()",
 		"echo.Context":        "ctx",
-		"*echo.Context":        "ctx",
+		"*echo.Context":       "ctx",
 	}
 	if echo.isV5 {
 		args["http.ResponseWriter"] = "ctx.Response()"
-		args["io.Writer"] =           "ctx.Response()"
+		args["io.Writer"] = "ctx.Response()"
 	}
 	s, ok := args[typeStr]
 	return s, ok
@@ -64,7 +64,7 @@ func (chi *echoPlugin) HeaderFunctions() []Function {
 	return []Function{
 		{
 			Required:    true,
-			Format:      "ctx.Request().Header.Get(r, \"%s\")",
+			Format:      "ctx.Request().Header.Get(\"%s\")",
 			IsArray:     false,
 			ResultType:  "string",
 			ResultError: false,
@@ -80,7 +80,7 @@ func (chi *echoPlugin) HeaderFunctions() []Function {
 		},
 		{
 			Required:    false,
-			Format:      "ctx.Request().Header[\"%s\"]",
+			Format:      "ctx.Request().Header.Values(\"%s\")",
 			IsArray:     true,
 			ResultType:  "string",
 			ResultError: false,
@@ -142,10 +142,56 @@ func (echo *echoPlugin) MiddlewaresDeclaration() string {
 	return "handlers ...echo.MiddlewareFunc"
 }
 
+func (echo *echoPlugin) MiddlewareTypeName() string {
+	return "echo.MiddlewareFunc"
+}
+
+func (echo *echoPlugin) RenderMiddlewareFunc(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "func(next echo.HandlerFunc) echo.HandlerFunc {")
+	if echo.isV5 {
+		io.WriteString(out, "\r\n\treturn func(ctx *echo.Context) error {")
+	} else {
+		io.WriteString(out, "\r\n\treturn func(ctx echo.Context) error {")
+	}
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\treturn next(ctx)\r\n\t}\r\n}")
+	return err
+}
+
+func (echo *echoPlugin) NamedMiddlewaresDeclaration() string {
+	return "middlewares map[string]" + echo.MiddlewareTypeName()
+}
+
 func (echo *echoPlugin) RenderWithMiddlewares(mux string) string {
 	return ""
 }
 
+func (echo *echoPlugin) RenderSetContext(out io.Writer, stdctx string) error {
+	_, err := io.WriteString(out, "\r\n\tctx.SetRequest(ctx.Request().WithContext("+stdctx+"))")
+	return err
+}
+
+func (echo *echoPlugin) RenderDeferWritten(out io.Writer, observation string) error {
+	if echo.isV5 {
+		_, err := io.WriteString(out, "\r\n\tdefer func() {"+
+			"\r\n\t\tstatusCode := 0"+
+			"\r\n\t\tif resp, err := echo.UnwrapResponse(ctx.Response()); err == nil {"+
+			"\r\n\t\t\tstatusCode = resp.Status"+
+			"\r\n\t\t}"+
+			"\r\n\t\t"+observation+".Written(statusCode)"+
+			"\r\n\t}()")
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\tdefer func() { "+observation+".Written(ctx.Response().Status) }()")
+	return err
+}
+
+func (echo *echoPlugin) RenderRecover(out io.Writer, fn func(io.Writer) error) error {
+	return renderRecoverWithError(out, fn)
+}
+
 func (echo *echoPlugin) ReadBodyFunc(argName string) string {
 	return "ctx.Bind(" + argName + ")"
 }
@@ -158,7 +204,7 @@ func (echo *echoPlugin) ReadBodyFunc(argName string) string {
 // 	return getCastErrorText(echo.cfg.NewBadArgument, method, accessFields, err, value)
 // }
 
-func (echo *echoPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, fn func(out io.Writer) error) error {
+func (echo *echoPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, middlewares []string, handler func(out io.Writer) error) error {
 	urlstr, err := ConvertURL(route.Path, false, Colon)
 	if err != nil {
 		return err
@@ -170,10 +216,32 @@ func (echo *echoPlugin) RenderFunc(out io.Writer, method *Method, route swag.Rou
 	if err != nil {
 		return err
 	}
+	if err := handler(out); err != nil {
+		return err
+	}
+	if len(middlewares) > 0 {
+		_, err = io.WriteString(out, ", append(handlers, "+strings.Join(middlewares, ", ")+")...)")
+		return err
+	}
+	_, err = io.WriteString(out, ", handlers...)")
+	return err
+}
+
+func (echo *echoPlugin) HandlerTypeName() string {
+	return "echo.HandlerFunc"
+}
+
+func (echo *echoPlugin) RenderHandlerFunc(out io.Writer, method *Method, fn func(out io.Writer) error) error {
+	// 有 recoverPanic 时返回值被命名为 handlerErr, 以便在 recover 时返回错误
+	result := "error"
+	if echo.cfg.RecoverPanic != "" {
+		result = "(handlerErr error)"
+	}
+	var err error
 	if echo.isV5 {
-	_, err = io.WriteString(out, "func(ctx *echo.Context) error {")
+		_, err = io.WriteString(out, "func(ctx *echo.Context) "+result+" {")
 	} else {
-	_, err = io.WriteString(out, "func(ctx echo.Context) error {")
+		_, err = io.WriteString(out, "func(ctx echo.Context) "+result+" {")
 	}
 	if err != nil {
 		return err
@@ -181,22 +249,32 @@ func (echo *echoPlugin) RenderFunc(out io.Writer, method *Method, route swag.Rou
 	if err := fn(out); err != nil {
 		return err
 	}
-	_, err = io.WriteString(out, "\r\n}, handlers...)")
+	_, err = io.WriteString(out, "\r\n}")
 	return err
 }
 
 func (echo *echoPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
 	txt := getBodyErrorText(echo.cfg.NewBadArgument, method, bodyName, err)
+	if echo.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, echo, &echo.cfg, method, "http.StatusBadRequest", txt, bodyName)
+	}
 
 	return echo.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (echo *echoPlugin) RenderCastError(out io.Writer, method *Method, accessFields, value, err string) error {
 	txt := getCastErrorText(echo.cfg.NewBadArgument, method, accessFields, err, value)
+	if echo.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, echo, &echo.cfg, method, "http.StatusBadRequest", txt, accessFields)
+	}
 	return echo.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (echo *echoPlugin) RenderReturnError(out io.Writer, method *Method, errCode, err string, errwrapped ...bool) error {
+	if echo.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, echo, &echo.cfg, method, errCode, err, "")
+	}
+
 	hasRealErrorCode := errCode != ""
 	if errCode == "" && echo.cfg.HttpCodeWith != "" {
 		errCode = echo.cfg.HttpCodeWith + "(" + err + ")"
@@ -215,16 +293,16 @@ func (echo *echoPlugin) RenderReturnError(out io.Writer, method *Method, errCode
 	if echo.customReturnFunc {
 		// 前面当  method.Operation.Produces 为 text/plain 时添加了 ".Error()", 这里要删除
 		err = strings.TrimSuffix(err, ".Error()")
-		text = `return `+echo.returnErrorResultFuncName+`(ctx, {{.err}}{{if and .errCode .hasRealErrorCode}},{{.errCode}}{{end}})`
+		text = `return ` + echo.returnErrorResultFuncName + `(ctx, {{.err}}{{if and .errCode .hasRealErrorCode}},{{.errCode}}{{end}})`
 	} else {
 		hasRealErrorCode = true
-		text = `return ctx.`+renderFunc+`(`+
-			`{{if .hasRealErrorCode -}}{{.errCode}}{{else}}http.StatusInternalServerError{{end}},`+
+		text = `return ctx.` + renderFunc + `(` +
+			`{{if .hasRealErrorCode -}}{{.errCode}}{{else}}http.StatusInternalServerError{{end}},` +
 			` {{.err}})`
 	}
 
 	s := renderString(text,
-			map[string]interface{}{
+		map[string]interface{}{
 			"err":              err,
 			"hasRealErrorCode": hasRealErrorCode,
 			"errCode":          errCode,
@@ -255,14 +333,17 @@ func (echo *echoPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 	if statusCode != "" {
 		args["statusCode"] = statusCode
 	} else {
-		args["statusCode"] = statusCodeLiteralByMethod(method.Operation.RouterProperties[0].HTTPMethod)
+		args["statusCode"] = successStatusCode(method)
+		if code := successStatusCode(method); code != statusCodeLiteralByMethod(method.routeHTTPMethod()) {
+			args["withCode"] = code
+		}
 	}
 
 	if withCode := WithCode(method); withCode != "" {
-			args["withCode"] = withCode
+		args["withCode"] = withCode
 	}
 
-	args["method"] = strings.ToUpper(method.Operation.RouterProperties[0].HTTPMethod)
+	args["method"] = strings.ToUpper(method.routeHTTPMethod())
 
 	if len(method.Operation.Produces) == 1 &&
 		method.Operation.Produces[0] == "text/plain" {
@@ -290,31 +371,27 @@ func (echo *echoPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 		return e
 	}
 
-
-
-
 	text := `{{- if .noreturn -}}
   return nil
 {{- else -}}
   return ctx.JSON({{.statusCode}}, {{.data}})
 {{- end}}`
 
-
 	if echo.customReturnFunc {
 		text = `{{- if .noreturn -}}
 	return nil
 	{{- else if .withCode -}} 
-	return `+echo.returnResultFuncName + `(ctx, {{.withCode}}, {{.data}})
+	return ` + echo.returnResultFuncName + `(ctx, {{.withCode}}, {{.data}})
 	{{- else if eq .method "POST" -}} 
-	return `+echo.returnCreatedResultFuncName + `(ctx, {{.data}})
+	return ` + echo.returnCreatedResultFuncName + `(ctx, {{.data}})
 	{{- else if eq .method "PUT" -}}
-	return `+echo.returnUpdatedResultFuncName + `(ctx, {{.data}})
+	return ` + echo.returnUpdatedResultFuncName + `(ctx, {{.data}})
 	{{- else if eq .method "DELETE" -}}
-	return `+echo.returnDeletedResultFuncName + `(ctx, {{.data}})
+	return ` + echo.returnDeletedResultFuncName + `(ctx, {{.data}})
 	{{- else if eq .method "GET" -}}
-	return `+echo.returnQueryResultFuncName + `(ctx, {{.data}})
+	return ` + echo.returnQueryResultFuncName + `(ctx, {{.data}})
 	{{- else -}}
-	return `+echo.returnResultFuncName + `(ctx, {{.statusCode}}, {{.data}})
+	return ` + echo.returnResultFuncName + `(ctx, {{.statusCode}}, {{.data}})
 	{{- end}}`
 	}
 
@@ -323,6 +400,11 @@ func (echo *echoPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 	return e
 }
 
+func (echo *echoPlugin) RenderReturnBlob(out io.Writer, method *Method, statusCode, contentType, data string) error {
+	_, e := io.WriteString(out, "\r\n\treturn ctx.Blob("+statusCode+", \""+contentType+"\", "+data+")")
+	return e
+}
+
 func (echo *echoPlugin) RenderReturnEmpty(out io.Writer, method *Method) error {
 	_, e := io.WriteString(out, "return nil")
 	return e
@@ -39,6 +39,23 @@ func (gin *ginPlugin) MiddlewaresDeclaration() string {
 	return "handlers ...gin.HandlerFunc"
 }
 
+func (gin *ginPlugin) MiddlewareTypeName() string {
+	return "gin.HandlerFunc"
+}
+
+func (gin *ginPlugin) RenderMiddlewareFunc(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "func(ctx *gin.Context) {")
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\tctx.Next()\r\n}")
+	return err
+}
+
+func (gin *ginPlugin) NamedMiddlewaresDeclaration() string {
+	return "middlewares map[string]" + gin.MiddlewareTypeName()
+}
+
 func (gin *ginPlugin) MiddlewaresVar() string {
 	return "handlers..."
 }
@@ -51,11 +68,25 @@ func (gin *ginPlugin) RenderWithMiddlewares(mux string) string {
 	return ""
 }
 
+func (gin *ginPlugin) RenderSetContext(out io.Writer, stdctx string) error {
+	_, err := io.WriteString(out, "\r\n\tctx.Request = ctx.Request.WithContext("+stdctx+")")
+	return err
+}
+
+func (gin *ginPlugin) RenderDeferWritten(out io.Writer, observation string) error {
+	_, err := io.WriteString(out, "\r\n\tdefer func() { "+observation+".Written(ctx.Writer.Status()) }()")
+	return err
+}
+
+func (gin *ginPlugin) RenderRecover(out io.Writer, fn func(io.Writer) error) error {
+	return renderRecover(out, fn)
+}
+
 func (chi *ginPlugin) HeaderFunctions() []Function {
 	return []Function{
 		{
 			Required:    true,
-			Format:      "ctx.Request().Header.Get(r, \"%s\")",
+			Format:      "ctx.Request.Header.Get(\"%s\")",
 			IsArray:     false,
 			ResultType:  "string",
 			ResultError: false,
@@ -63,7 +94,7 @@ func (chi *ginPlugin) HeaderFunctions() []Function {
 		},
 		{
 			Required:    false,
-			Format:      "ctx.Request().Header.Get(\"%s\")",
+			Format:      "ctx.Request.Header.Get(\"%s\")",
 			IsArray:     false,
 			ResultType:  "string",
 			ResultError: false,
@@ -71,7 +102,7 @@ func (chi *ginPlugin) HeaderFunctions() []Function {
 		},
 		{
 			Required:    false,
-			Format:      "ctx.Request().Header[\"%s\"]",
+			Format:      "ctx.Request.Header.Values(\"%s\")",
 			IsArray:     true,
 			ResultType:  "string",
 			ResultError: false,
@@ -135,7 +166,7 @@ func (gin *ginPlugin) ReadBodyFunc(argName string) string {
 // 	return getCastErrorText(gin.cfg.NewBadArgument, method, accessFields, err, value)
 // }
 
-func (gin *ginPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, fn func(out io.Writer) error) error {
+func (gin *ginPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, middlewares []string, handler func(out io.Writer) error) error {
 	urlstr, err := ConvertURL(route.Path, false, Colon)
 	if err != nil {
 		return err
@@ -143,29 +174,56 @@ func (gin *ginPlugin) RenderFunc(out io.Writer, method *Method, route swag.Route
 	// if urlstr == "/" {
 	// 	urlstr = ""
 	// }
-	_, err = io.WriteString(out, "\r\nmux."+strings.ToUpper(route.HTTPMethod)+"(\""+urlstr+"\", append(handlers, func(ctx *gin.Context) {")
+	handlers := "handlers"
+	if len(middlewares) > 0 {
+		handlers += ", " + strings.Join(middlewares, ", ")
+	}
+	_, err = io.WriteString(out, "\r\nmux."+strings.ToUpper(route.HTTPMethod)+"(\""+urlstr+"\", append("+handlers+", ")
 	if err != nil {
 		return err
 	}
+	if err := handler(out); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "))")
+	return err
+}
+
+func (gin *ginPlugin) HandlerTypeName() string {
+	return "gin.HandlerFunc"
+}
+
+func (gin *ginPlugin) RenderHandlerFunc(out io.Writer, method *Method, fn func(out io.Writer) error) error {
+	io.WriteString(out, "func(ctx *gin.Context) {")
 	if err := fn(out); err != nil {
 		return err
 	}
-	_, err = io.WriteString(out, "\r\n}))")
+	_, err := io.WriteString(out, "\r\n}")
 	return err
 }
 
 func (gin *ginPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
 	txt := getBodyErrorText(gin.cfg.NewBadArgument, method, bodyName, err)
+	if gin.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, gin, &gin.cfg, method, "http.StatusBadRequest", txt, bodyName)
+	}
 
 	return gin.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (gin *ginPlugin) RenderCastError(out io.Writer, method *Method, accessFields, value, err string) error {
 	txt := getCastErrorText(gin.cfg.NewBadArgument, method, accessFields, err, value)
+	if gin.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, gin, &gin.cfg, method, "http.StatusBadRequest", txt, accessFields)
+	}
 	return gin.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (gin *ginPlugin) RenderReturnError(out io.Writer, method *Method, errCode, err string, errwrapped ...bool) error {
+	if gin.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, gin, &gin.cfg, method, errCode, err, "")
+	}
+
 	if errCode == "" && gin.cfg.HttpCodeWith != "" {
 		errCode = gin.cfg.HttpCodeWith + "(" + err + ")"
 	}
@@ -215,7 +273,7 @@ func (gin *ginPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode,
 	if statusCode != "" {
 		args["statusCode"] = statusCode
 	} else {
-		args["statusCode"] = statusCodeLiteralByMethod(method.Operation.RouterProperties[0].HTTPMethod)
+		args["statusCode"] = successStatusCode(method)
 	}
 
 	renderFunc := "JSON"
@@ -233,6 +291,11 @@ func (gin *ginPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode,
 	return e
 }
 
+func (gin *ginPlugin) RenderReturnBlob(out io.Writer, method *Method, statusCode, contentType, data string) error {
+	_, e := io.WriteString(out, "\r\n\tctx.Data("+statusCode+", \""+contentType+"\", "+data+")\r\n\treturn")
+	return e
+}
+
 func (gin *ginPlugin) RenderReturnEmpty(out io.Writer, method *Method) error {
 	_, e := io.WriteString(out, "return")
 	return e
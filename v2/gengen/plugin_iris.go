@@ -3,6 +3,7 @@ package gengen
 import (
 	"io"
 	"os"
+	"strings"
 
 	"github.com/swaggo/swag"
 )
@@ -43,7 +44,7 @@ func (chi *irisPlugin) HeaderFunctions() []Function {
 	return []Function{
 		{
 			Required:    true,
-			Format:      "ctx.Request().Header.Get(r, \"%s\")",
+			Format:      "ctx.Request().Header.Get(\"%s\")",
 			IsArray:     false,
 			ResultType:  "string",
 			ResultError: false,
@@ -59,7 +60,7 @@ func (chi *irisPlugin) HeaderFunctions() []Function {
 		},
 		{
 			Required:    false,
-			Format:      "ctx.Request().Header[\"%s\"]",
+			Format:      "ctx.Request().Header.Values(\"%s\")",
 			IsArray:     true,
 			ResultType:  "string",
 			ResultError: false,
@@ -139,6 +140,23 @@ func (iris *irisPlugin) MiddlewaresDeclaration() string {
 	return "handlers ...iris.Handler"
 }
 
+func (iris *irisPlugin) MiddlewareTypeName() string {
+	return "iris.Handler"
+}
+
+func (iris *irisPlugin) RenderMiddlewareFunc(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "func(ctx iris.Context) {")
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\tctx.Next()\r\n}")
+	return err
+}
+
+func (iris *irisPlugin) NamedMiddlewaresDeclaration() string {
+	return "middlewares map[string]" + iris.MiddlewareTypeName()
+}
+
 func (iris *irisPlugin) MiddlewaresVar() string {
 	return "handlers..."
 }
@@ -151,6 +169,20 @@ func (iris *irisPlugin) RenderWithMiddlewares(mux string) string {
 	return ""
 }
 
+func (iris *irisPlugin) RenderSetContext(out io.Writer, stdctx string) error {
+	_, err := io.WriteString(out, "\r\n\tctx.ResetRequest(ctx.Request().WithContext("+stdctx+"))")
+	return err
+}
+
+func (iris *irisPlugin) RenderDeferWritten(out io.Writer, observation string) error {
+	_, err := io.WriteString(out, "\r\n\tdefer func() { "+observation+".Written(ctx.GetStatusCode()) }()")
+	return err
+}
+
+func (iris *irisPlugin) RenderRecover(out io.Writer, fn func(io.Writer) error) error {
+	return renderRecover(out, fn)
+}
+
 func (iris *irisPlugin) RenderMiddlewares(out io.Writer, fn func(out io.Writer) error) error {
 	io.WriteString(out, "append(handlers,")
 	err := fn(out)
@@ -174,7 +206,7 @@ func (iris *irisPlugin) ReadBodyFunc(argName string) string {
 // 	return getCastErrorText(iris.cfg.NewBadArgument, method, accessFields, err, value)
 // }
 
-func (iris *irisPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, fn func(out io.Writer) error) error {
+func (iris *irisPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, middlewares []string, handler func(out io.Writer) error) error {
 	urlstr, err := ConvertURL(route.Path, false, Colon)
 	if err != nil {
 		return err
@@ -183,14 +215,31 @@ func (iris *irisPlugin) RenderFunc(out io.Writer, method *Method, route swag.Rou
 	// 	urlstr = ""
 	// }
 
-	_, err = io.WriteString(out, "\r\nmux."+ConvertMethodNameToCamelCase(route.HTTPMethod)+"(\""+urlstr+"\", append(handlers, func(ctx iris.Context) {")
+	handlers := "handlers"
+	if len(middlewares) > 0 {
+		handlers += ", " + strings.Join(middlewares, ", ")
+	}
+	_, err = io.WriteString(out, "\r\nmux."+ConvertMethodNameToCamelCase(route.HTTPMethod)+"(\""+urlstr+"\", append("+handlers+", ")
 	if err != nil {
 		return err
 	}
+	if err := handler(out); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "))")
+	return err
+}
+
+func (iris *irisPlugin) HandlerTypeName() string {
+	return "iris.Handler"
+}
+
+func (iris *irisPlugin) RenderHandlerFunc(out io.Writer, method *Method, fn func(out io.Writer) error) error {
+	io.WriteString(out, "func(ctx iris.Context) {")
 	if err := fn(out); err != nil {
 		return err
 	}
-	_, err = io.WriteString(out, "\r\n}))")
+	_, err := io.WriteString(out, "\r\n}")
 	return err
 }
 
@@ -215,8 +264,8 @@ func (iris *irisPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 	}
 	if statusCode != "" {
 		args["statusCode"] = statusCode
-		// } else {
-		// args["statusCode"] = statusCodeLiteralByMethod(method.Operation.RouterProperties[0].HTTPMethod)
+	} else if code := declaredStatusCode(method); code != "" {
+		args["statusCode"] = code
 	}
 	renderFunc := "JSON"
 	if len(method.Operation.Produces) == 1 &&
@@ -228,8 +277,8 @@ func (iris *irisPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
   return
 {{- else -}}
   {{- if .statusCode -}}
-	ctx.StatusCode(r, {{.statusCode}})
-  {{- end -}}
+	ctx.StatusCode({{.statusCode}})
+  {{end -}}
 	ctx.`+renderFunc+`({{.data}})
   return
 {{- end}}`, args)
@@ -239,16 +288,26 @@ func (iris *irisPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 
 func (iris *irisPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
 	txt := getBodyErrorText(iris.cfg.NewBadArgument, method, bodyName, err)
+	if iris.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, iris, &iris.cfg, method, "http.StatusBadRequest", txt, bodyName)
+	}
 
 	return iris.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (iris *irisPlugin) RenderCastError(out io.Writer, method *Method, accessFields, value, err string) error {
 	txt := getCastErrorText(iris.cfg.NewBadArgument, method, accessFields, err, value)
+	if iris.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, iris, &iris.cfg, method, "http.StatusBadRequest", txt, accessFields)
+	}
 	return iris.RenderReturnError(out, method, "http.StatusBadRequest", txt, true)
 }
 
 func (iris *irisPlugin) RenderReturnError(out io.Writer, method *Method, errCode, err string, errwrapped ...bool) error {
+	if iris.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, iris, &iris.cfg, method, errCode, err, "")
+	}
+
 	if errCode == "" && iris.cfg.HttpCodeWith != "" {
 		errCode = iris.cfg.HttpCodeWith + "(" + err + ")"
 	}
@@ -277,6 +336,10 @@ func (iris *irisPlugin) RenderReturnError(out io.Writer, method *Method, errCode
 	return e
 }
 
+func (iris *irisPlugin) RenderReturnBlob(out io.Writer, method *Method, statusCode, contentType, data string) error {
+	return renderWriteBlob(out, iris, method, statusCode, contentType, data)
+}
+
 func (iris *irisPlugin) RenderReturnEmpty(out io.Writer, method *Method) error {
 	_, e := io.WriteString(out, "return")
 	return e
@@ -35,12 +35,11 @@ func (lng *loongPlugin) GetSpecificTypeArgument(typeStr string) (string, bool) {
 	return s, ok
 }
 
-
 func (chi *loongPlugin) HeaderFunctions() []Function {
 	return []Function{
 		{
 			Required:    true,
-			Format:      "ctx.Request().Header.Get(r, \"%s\")",
+			Format:      "ctx.Request().Header.Get(\"%s\")",
 			IsArray:     false,
 			ResultType:  "string",
 			ResultError: false,
@@ -56,7 +55,7 @@ func (chi *loongPlugin) HeaderFunctions() []Function {
 		},
 		{
 			Required:    false,
-			Format:      "ctx.Request().Header[\"%s\"]",
+			Format:      "ctx.Request().Header.Values(\"%s\")",
 			IsArray:     true,
 			ResultType:  "string",
 			ResultError: false,
@@ -112,6 +111,24 @@ func (lng *loongPlugin) MiddlewaresDeclaration() string {
 	return "handlers ...loong.MiddlewareFunc"
 }
 
+func (lng *loongPlugin) MiddlewareTypeName() string {
+	return "loong.MiddlewareFunc"
+}
+
+func (lng *loongPlugin) RenderMiddlewareFunc(out io.Writer, fn func(io.Writer) error) error {
+	io.WriteString(out, "func(next loong.HandlerFunc) loong.HandlerFunc {")
+	io.WriteString(out, "\r\n\treturn func(ctx *loong.Context) error {")
+	if err := fn(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\r\n\treturn next(ctx)\r\n\t}\r\n}")
+	return err
+}
+
+func (lng *loongPlugin) NamedMiddlewaresDeclaration() string {
+	return "middlewares map[string]" + lng.MiddlewareTypeName()
+}
+
 func (lng *loongPlugin) MiddlewaresVar() string {
 	return "handlers..."
 }
@@ -121,7 +138,21 @@ func (lng *loongPlugin) HasWithMiddlewares() bool {
 }
 
 func (lng *loongPlugin) RenderWithMiddlewares(mux string) string {
-	return mux + " = "+mux+".With(handlers...)"
+	return mux + " = " + mux + ".With(handlers...)"
+}
+
+func (lng *loongPlugin) RenderSetContext(out io.Writer, stdctx string) error {
+	_, err := io.WriteString(out, "\r\n\tctx.StdContext = "+stdctx)
+	return err
+}
+
+func (lng *loongPlugin) RenderDeferWritten(out io.Writer, observation string) error {
+	_, err := io.WriteString(out, "\r\n\tdefer func() { "+observation+".Written(ctx.Response().Status) }()")
+	return err
+}
+
+func (lng *loongPlugin) RenderRecover(out io.Writer, fn func(io.Writer) error) error {
+	return renderRecoverWithError(out, fn)
 }
 
 func (lng *loongPlugin) RenderMiddlewares(out io.Writer, fn func(out io.Writer) error) error {
@@ -140,7 +171,7 @@ func (lng *loongPlugin) GetCastErrorText(method *Method, accessFields, err, valu
 	return "loong.ErrBadArgument(\"" + accessFields + "\", " + value + ", " + err + ")"
 }
 
-func (lng *loongPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, fn func(out io.Writer) error) error {
+func (lng *loongPlugin) RenderFunc(out io.Writer, method *Method, route swag.RouteProperties, middlewares []string, handler func(out io.Writer) error) error {
 	urlstr, err := ConvertURL(route.Path, false, Colon)
 	if err != nil {
 		return err
@@ -148,28 +179,63 @@ func (lng *loongPlugin) RenderFunc(out io.Writer, method *Method, route swag.Rou
 	if urlstr == "/" {
 		urlstr = ""
 	}
-	_, err = io.WriteString(out, "\r\nmux."+strings.ToUpper(route.HTTPMethod)+"(\""+urlstr+"\", func(ctx *loong.Context) error {")
+	mux := "mux"
+	if len(middlewares) > 0 {
+		mux += ".With(" + strings.Join(middlewares, ", ") + ")"
+	}
+	_, err = io.WriteString(out, "\r\n"+mux+"."+strings.ToUpper(route.HTTPMethod)+"(\""+urlstr+"\", ")
+	if err != nil {
+		return err
+	}
+	if err := handler(out); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, ")")
+	return err
+}
+
+func (lng *loongPlugin) HandlerTypeName() string {
+	return "loong.HandlerFunc"
+}
+
+func (lng *loongPlugin) RenderHandlerFunc(out io.Writer, method *Method, fn func(out io.Writer) error) error {
+	// 有 recoverPanic 时返回值被命名为 handlerErr, 以便在 recover 时返回错误
+	result := "error"
+	if lng.cfg.RecoverPanic != "" {
+		result = "(handlerErr error)"
+	}
+	_, err := io.WriteString(out, "func(ctx *loong.Context) "+result+" {")
 	if err != nil {
 		return err
 	}
 	if err := fn(out); err != nil {
 		return err
 	}
-	_, err = io.WriteString(out, "\r\n})")
+	_, err = io.WriteString(out, "\r\n}")
 	return err
 }
 
 func (lng *loongPlugin) RenderBodyError(out io.Writer, method *Method, bodyName, err string) error {
 	txt := lng.GetBodyErrorText(method, bodyName, err)
+	if lng.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, lng, &lng.cfg, method, "http.StatusBadRequest", txt, bodyName)
+	}
 	return lng.RenderReturnError(out, method, "http.StatusBadRequest", txt, false)
 }
 
 func (lng *loongPlugin) RenderCastError(out io.Writer, method *Method, accessFields, value, err string) error {
 	txt := lng.GetCastErrorText(method, accessFields, err, value)
+	if lng.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, lng, &lng.cfg, method, "http.StatusBadRequest", txt, accessFields)
+	}
 	return lng.RenderReturnError(out, method, "http.StatusBadRequest", txt, false)
 }
 
 func (lng *loongPlugin) RenderReturnError(out io.Writer, method *Method, errCode, err string, errwrapped ...bool) error {
+	if lng.cfg.WriteProblem != "" {
+		return renderReturnProblem(out, lng, &lng.cfg, method, errCode, err, "")
+	}
+
 	s := renderString(`return ctx.ReturnError({{.err}}{{if and .errCode .hasRealErrorCode}},{{.errCode}}{{end}})`,
 		map[string]interface{}{
 			"err":              err,
@@ -202,14 +268,13 @@ func (lng *loongPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 	if statusCode != "" {
 		args["statusCode"] = statusCode
 	} else {
-		args["statusCode"] = statusCodeLiteralByMethod(method.Operation.RouterProperties[0].HTTPMethod)
-
-		if withCode := WithCode(method); withCode != "" {
-			args["withCode"] = withCode
+		args["statusCode"] = successStatusCode(method)
+		if code := successStatusCode(method); code != statusCodeLiteralByMethod(method.routeHTTPMethod()) {
+			args["withCode"] = code
 		}
 	}
 
-	args["method"] = strings.ToUpper(method.Operation.RouterProperties[0].HTTPMethod)
+	args["method"] = strings.ToUpper(method.routeHTTPMethod())
 
 	if len(method.Operation.Produces) == 1 &&
 		method.Operation.Produces[0] == "text/plain" {
@@ -256,6 +321,11 @@ func (lng *loongPlugin) RenderReturnOK(out io.Writer, method *Method, statusCode
 	return e
 }
 
+func (lng *loongPlugin) RenderReturnBlob(out io.Writer, method *Method, statusCode, contentType, data string) error {
+	_, e := io.WriteString(out, "\r\n\treturn ctx.Blob("+statusCode+", \""+contentType+"\", "+data+")")
+	return e
+}
+
 func (lng *loongPlugin) RenderReturnEmpty(out io.Writer, method *Method) error {
 	_, e := io.WriteString(out, "return nil")
 	return e
@@ -0,0 +1,268 @@
+package gengen
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/runner-mei/GoBatis/cmd/gobatis/goparser2/astutil"
+)
+
+// readSecuritySchemes 读取包中所有文件里用 @securityDefinitions 声明的认证方式, 格式与 swag 相同, 如
+//
+//	// @securityDefinitions.apikey ApiKeyAuth
+//	// @in header
+//	// @name X-API-Key
+func readSecuritySchemes(file *astutil.File) map[string]*spec.SecurityScheme {
+	schemes := map[string]*spec.SecurityScheme{}
+	readSecurityDefinitions(schemes, file)
+	if file.Package == nil {
+		return schemes
+	}
+	for i := 0; i < file.Package.FileCount(); i++ {
+		f, err := file.Package.GetFileByIndex(i)
+		if err != nil || f == file {
+			continue
+		}
+		readSecurityDefinitions(schemes, f)
+	}
+	return schemes
+}
+
+func readSecurityDefinitions(schemes map[string]*spec.SecurityScheme, file *astutil.File) {
+	if file.AstFile == nil {
+		return
+	}
+	for _, comment := range file.AstFile.Comments {
+		var current *spec.SecurityScheme
+		for _, line := range strings.Split(comment.Text(), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			attr := strings.ToLower(fields[0])
+			value := strings.TrimSpace(strings.TrimSpace(line)[len(fields[0]):])
+
+			switch {
+			case attr == "@securitydefinitions.basic":
+				current = spec.BasicAuth()
+				schemes[value] = current
+			case attr == "@securitydefinitions.apikey":
+				current = spec.APIKeyAuth("", "")
+				schemes[value] = current
+			case strings.HasPrefix(attr, "@securitydefinitions.oauth2."):
+				current = &spec.SecurityScheme{}
+				current.Type = "oauth2"
+				schemes[value] = current
+			case strings.HasPrefix(attr, "@securitydefinitions."):
+				current = nil
+			case attr == "@in" && current != nil:
+				current.In = value
+			case attr == "@name" && current != nil:
+				current.Name = value
+			}
+		}
+	}
+}
+
+// securityNames 返回方法中 @Security 用到的认证方式的名称
+func securityNames(methods []*Method) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, method := range methods {
+		for _, requirement := range method.Operation.Security {
+			for name := range requirement {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func authenticatorName(ts *astutil.TypeSpec) string {
+	return ts.Name + "Authenticator"
+}
+
+// isBearerScheme 判断是不是放在 Authorization 头中的 Bearer token
+func isBearerScheme(scheme *spec.SecurityScheme) bool {
+	return scheme != nil && (scheme.Type == "oauth2" ||
+		(scheme.Type == "apiKey" && scheme.In == "header" && strings.EqualFold(scheme.Name, "Authorization")))
+}
+
+// genAuthenticatorInterface 输出认证的接口, 每个认证方式对应一个方法, 参数按认证方式的类型不同而不同,
+// 没有用 @securityDefinitions 声明的认证方式传入 *http.Request
+func genAuthenticatorInterface(out io.Writer, ts *astutil.TypeSpec, names []string, schemes map[string]*spec.SecurityScheme) {
+	io.WriteString(out, "\r\n\r\ntype "+authenticatorName(ts)+" interface {")
+	for _, name := range names {
+		scheme := schemes[name]
+		io.WriteString(out, "\r\n\t"+CamelCase(name)+"(ctx context.Context, ")
+		switch {
+		case scheme == nil:
+			io.WriteString(out, "r *http.Request, scopes []string) error")
+		case scheme.Type == "basic":
+			io.WriteString(out, "username, password string) error")
+		case scheme.Type == "oauth2":
+			io.WriteString(out, "token string, scopes []string) error")
+		case isBearerScheme(scheme):
+			io.WriteString(out, "token string) error")
+		default:
+			io.WriteString(out, "key string) error")
+		}
+	}
+	io.WriteString(out, "\r\n}")
+}
+
+// renderAuthenticate 在绑定参数之前调用认证的接口, 多个 @Security 之间是或的关系,
+// 同一个 @Security 中的多个认证方式是与的关系, 认证失败时返回 401
+func (method *Method) renderAuthenticate(ctx *GenContext) {
+	if len(method.Operation.Security) == 0 {
+		return
+	}
+	r, _ := ctx.plugin.GetSpecificTypeArgument("*http.Request")
+	stdctx, _ := ctx.plugin.GetSpecificTypeArgument("context.Context")
+
+	io.WriteString(ctx.out, "\r\n\tvar authErr error")
+	for idx, requirement := range method.Operation.Security {
+		if idx > 0 {
+			io.WriteString(ctx.out, "\r\n\tif authErr != nil {")
+		}
+
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for nameIdx, name := range names {
+			if nameIdx > 0 {
+				io.WriteString(ctx.out, "\r\n\tif authErr == nil {")
+			}
+			renderAuthenticateScheme(ctx.out, r, stdctx, name, requirement[name], ctx.securitySchemes[name])
+			if nameIdx > 0 {
+				io.WriteString(ctx.out, "\r\n\t}")
+			}
+		}
+
+		if idx > 0 {
+			io.WriteString(ctx.out, "\r\n\t}")
+		}
+	}
+	io.WriteString(ctx.out, "\r\n\tif authErr != nil {\r\n\t\t")
+	renderReturnRejected(ctx, method, "Authorization", "http.StatusUnauthorized", "authErr")
+	io.WriteString(ctx.out, "\r\n\t}")
+}
+
+func renderAuthenticateScheme(out io.Writer, r, stdctx, name string, scopes []string, scheme *spec.SecurityScheme) {
+	call := "authenticator." + CamelCase(name) + "(" + stdctx + ", "
+	switch {
+	case scheme == nil:
+		io.WriteString(out, "\r\n\tauthErr = "+call+r+", "+scopesLiteral(scopes)+")")
+	case scheme.Type == "basic":
+		io.WriteString(out, "\r\n\tif username, password, ok := "+r+".BasicAuth(); ok {")
+		io.WriteString(out, "\r\n\t\tauthErr = "+call+"username, password)")
+		io.WriteString(out, "\r\n\t} else {")
+		io.WriteString(out, "\r\n\t\tauthErr = errors.New(\"basic auth is missing\")")
+		io.WriteString(out, "\r\n\t}")
+	case scheme.Type == "oauth2":
+		io.WriteString(out, "\r\n\tauthErr = "+call+"strings.TrimPrefix("+r+".Header.Get(\"Authorization\"), \"Bearer \"), "+scopesLiteral(scopes)+")")
+	case isBearerScheme(scheme):
+		io.WriteString(out, "\r\n\tauthErr = "+call+"strings.TrimPrefix("+r+".Header.Get(\"Authorization\"), \"Bearer \"))")
+	case scheme.In == "query":
+		io.WriteString(out, "\r\n\tauthErr = "+call+r+".URL.Query().Get("+strconv.Quote(scheme.Name)+"))")
+	default:
+		io.WriteString(out, "\r\n\tauthErr = "+call+r+".Header.Get("+strconv.Quote(scheme.Name)+"))")
+	}
+}
+
+func scopesLiteral(scopes []string) string {
+	if len(scopes) == 0 {
+		return "nil"
+	}
+	var sb strings.Builder
+	sb.WriteString("[]string{")
+	for idx, scope := range scopes {
+		if idx > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.Quote(scope))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// genClientCredentialFields 输出客户端中每个认证方式的凭证字段, 没有用 @securityDefinitions 声明的认证方式不能自动附加
+func genClientCredentialFields(out io.Writer, names []string, schemes map[string]*spec.SecurityScheme) {
+	for _, name := range names {
+		scheme := schemes[name]
+		if scheme == nil {
+			continue
+		}
+		if scheme.Type == "basic" {
+			io.WriteString(out, "\r\n\t"+CamelCase(name)+" *url.Userinfo")
+		} else {
+			io.WriteString(out, "\r\n\t"+CamelCase(name)+" string")
+		}
+	}
+}
+
+// genClientCredentials 将方法的 @Security 中用到的凭证附加到请求中, 多个 @Security 之间是或的关系,
+// 所以按声明的顺序只附加第一个凭证都已设置的 @Security, 含有没有用 @securityDefinitions 声明的认证方式的 @Security 会被跳过
+func (cmd *ClientGenerator) genClientCredentials(out io.Writer, method *Method, schemes map[string]*spec.SecurityScheme, needAssignment *bool) {
+	isFirst := true
+	for _, requirement := range method.Operation.Security {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		conditions := make([]string, 0, len(names))
+		for _, name := range names {
+			scheme := schemes[name]
+			if scheme == nil {
+				conditions = nil
+				break
+			}
+			if scheme.Type == "basic" {
+				conditions = append(conditions, "client."+CamelCase(name)+" != nil")
+			} else {
+				conditions = append(conditions, "client."+CamelCase(name)+" != \"\"")
+			}
+		}
+		if len(conditions) == 0 {
+			continue
+		}
+
+		if isFirst {
+			io.WriteString(out, "\r\nif ")
+			isFirst = false
+		} else {
+			io.WriteString(out, " else if ")
+		}
+		io.WriteString(out, strings.Join(conditions, " && ")+" {")
+		for _, name := range names {
+			genClientCredential(out, "client."+CamelCase(name), schemes[name])
+		}
+		io.WriteString(out, "\r\n}")
+		*needAssignment = true
+	}
+}
+
+func genClientCredential(out io.Writer, field string, scheme *spec.SecurityScheme) {
+	switch {
+	case scheme.Type == "basic":
+		io.WriteString(out, "\r\n\tpassword, _ := "+field+".Password()")
+		io.WriteString(out, "\r\n\trequest = request.SetHeader(\"Authorization\", \"Basic \"+base64.StdEncoding.EncodeToString([]byte("+field+".Username()+\":\"+password)))")
+	case isBearerScheme(scheme):
+		io.WriteString(out, "\r\n\trequest = request.SetHeader(\"Authorization\", \"Bearer \"+"+field+")")
+	case scheme.In == "query":
+		io.WriteString(out, "\r\n\trequest = request.SetParam("+strconv.Quote(scheme.Name)+", "+field+")")
+	default:
+		io.WriteString(out, "\r\n\trequest = request.SetHeader("+strconv.Quote(scheme.Name)+", "+field+")")
+	}
+}
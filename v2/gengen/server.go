@@ -6,7 +6,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/runner-mei/GoBatis/cmd/gobatis/goparser2/astutil"
 	"github.com/swaggo/swag"
@@ -21,9 +24,19 @@ type ServerGenerator struct {
 
 	enableResultWrap   bool
 	convertNamespace   string
+	errorMapping       string
+	errorMappings      []ErrorMapping
 	outputHttpCodeWith bool
+	outputProblem      bool
+	outputRouteInfo    bool
+	outputObserver     bool
+	outputRecoverPanic bool
+	outputDeepObject   bool
 	convertParamTypes  string
-	importList            string
+
+	websocketPingPeriod time.Duration
+	websocketWriteWait  time.Duration
+	importList          string
 }
 
 func (cmd *ServerGenerator) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -55,7 +68,19 @@ func (cmd *ServerGenerator) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	fs.StringVar(&cmd.cfg.CustomReturnFunc, "customReturn", os.Getenv("GOGEN_CUSTOM_RETURN_FUNC"), "")
 
 	fs.BoolVar(&cmd.outputHttpCodeWith, "outputHttpCodeWith", false, "生成 httpCodeWith 函数")
+	fs.StringVar(&cmd.cfg.WriteProblem, "writeProblem", os.Getenv("GOGEN_WRITE_PROBLEM"), "使用 WriteProblem 函数以 application/problem+json 格式返回错误")
+	fs.BoolVar(&cmd.outputProblem, "outputProblem", false, "生成 Problem 类型和 WriteProblem 函数")
+	fs.StringVar(&cmd.cfg.RouteInfo, "routeInfo", os.Getenv("GOGEN_ROUTE_INFO"), "生成 <Iface>Routes 变量并将 RouteInfo 放在请求的 context 中")
+	fs.BoolVar(&cmd.outputRouteInfo, "outputRouteInfo", false, "生成 RouteInfo 类型和相关的函数")
+	fs.StringVar(&cmd.cfg.Observer, "observer", os.Getenv("GOGEN_OBSERVER"), "Init 函数增加一个 Observer 参数, 在处理请求的各个阶段调用它")
+	fs.BoolVar(&cmd.outputObserver, "outputObserver", false, "生成 Observer 和 Observation 接口")
+	fs.StringVar(&cmd.cfg.RecoverPanic, "recoverPanic", os.Getenv("GOGEN_RECOVER_PANIC"), "在处理函数中 recover panic, 并用 NewPanicError 函数将它转换为 error 后返回")
+	fs.BoolVar(&cmd.outputRecoverPanic, "outputRecoverPanic", false, "生成 PanicError 类型和 NewPanicError 函数")
+	fs.BoolVar(&cmd.outputDeepObject, "outputDeepObject", false, "生成 BindDeepObject 函数")
+	fs.DurationVar(&cmd.websocketPingPeriod, "websocketPingPeriod", 30*time.Second, "websocket 发送 ping 消息的间隔")
+	fs.DurationVar(&cmd.websocketWriteWait, "websocketWriteWait", 10*time.Second, "websocket 发送 ping 消息的超时时间")
 	fs.StringVar(&cmd.convertNamespace, "convert_ns", "", "转换函数的前缀")
+	fs.StringVar(&cmd.errorMapping, "errorMapping", os.Getenv("GOGEN_ERROR_MAPPING"), "error 到状态码的映射，如 ErrNotFound=404,*ValidationError=422")
 	fs.StringVar(&cmd.convertParamTypes, "convert_param_types", os.Getenv("GOGEN_CONVERT_PARAM_TYPES"), "自定义的转换类型，多个类型时以逗号分隔")
 
 	fs.StringVar(&cmd.importList, "imports", "", "自定义的转换类型，多个类型时以逗号分隔")
@@ -81,6 +106,10 @@ func (cmd *ServerGenerator) Run(args []string) error {
 	if err != nil {
 		return err
 	}
+	cmd.errorMappings, err = ParseErrorMappings(cmd.errorMapping)
+	if err != nil {
+		return err
+	}
 
 	if cmd.ext == "" {
 		cmd.ext = "." + cmd.plugin + "-gen.go"
@@ -98,15 +127,10 @@ func (cmd *ServerGenerator) Run(args []string) error {
 		if err != nil {
 			return err
 		}
-		err = swaggerParser.Packages().CollectAstFile(file.Package.ImportPath, file.Filename, file.AstFile)
-		if err != nil {
-			return errors.New("collect astFile: " + err.Error())
-		}
 		files = append(files, file)
 	}
-	_, err = swaggerParser.Packages().ParseTypes()
-	if err != nil {
-		return errors.New("parse types: " + err.Error())
+	if err := collectAstFiles(swaggerParser, files); err != nil {
+		return err
 	}
 
 	for idx, file := range files {
@@ -140,15 +164,7 @@ func (cmd *ServerGenerator) Run(args []string) error {
 }
 
 func (cmd *ServerGenerator) genHeader(cfg Plugin, out io.Writer, swaggerParser *swag.Parser, file *astutil.File) error {
-	if cmd.buildTag != "" {
-		io.WriteString(out, "//go:build ")
-		io.WriteString(out, cmd.buildTag)
-		io.WriteString(out, "\r\n")
-		io.WriteString(out, "// +build ")
-		io.WriteString(out, cmd.buildTag)
-		io.WriteString(out, "\r\n")
-		io.WriteString(out, "\r\n")
-	}
+	renderBuildTag(out, cmd.buildTag, file)
 	io.WriteString(out, "// Please don't edit this file!\r\npackage ")
 	io.WriteString(out, file.Pkg.Name)
 	io.WriteString(out, "\r\n\r\nimport (")
@@ -202,6 +218,13 @@ func (cmd *ServerGenerator) genHeader(cfg Plugin, out io.Writer, swaggerParser *
 		}
 		io.WriteString(out, "\""+pa+"\"")
 	}
+	// text/csv 的响应由 csvutil 编码, 只在有 @Produce csv 时才导入, websocket 用 gorilla/websocket, 只在有 @x-gogen-websocket 时才导入
+	if !isFileImport("csvutil") && producesCSV(file) {
+		io.WriteString(out, "\r\n\t\"github.com/jszwec/csvutil\"")
+	}
+	if !isFileImport("websocket") && hasWebsocketMethod(file) {
+		io.WriteString(out, "\r\n\t\"github.com/gorilla/websocket\"")
+	}
 
 	if cmd.importList != "" {
 		for _, pa := range strings.Split(cmd.importList, ",") {
@@ -245,11 +268,61 @@ func (cmd *ServerGenerator) genHeader(cfg Plugin, out io.Writer, swaggerParser *
 			io.WriteString(out, "\r\n")
 		}
 	}
+	if cmd.outputProblem {
+		txt := problemTxt
+		if cmd.cfg.WriteProblem != "" {
+			txt = strings.Replace(txt, "WriteProblem", cmd.cfg.WriteProblem, -1)
+		}
+
+		io.WriteString(out, "\r\n")
+		io.WriteString(out, txt)
+		io.WriteString(out, "\r\n")
+	}
+	if cmd.outputRouteInfo {
+		txt := routeInfoTxt
+		if cmd.cfg.RouteInfo != "" {
+			txt = strings.Replace(txt, "RouteInfo", cmd.cfg.RouteInfo, -1)
+		}
+
+		io.WriteString(out, "\r\n")
+		io.WriteString(out, txt)
+		io.WriteString(out, "\r\n")
+	}
+	if cmd.outputDeepObject {
+		io.WriteString(out, "\r\n")
+		io.WriteString(out, bindDeepObjectTxt)
+		io.WriteString(out, "\r\n")
+	}
+	if cmd.outputRecoverPanic {
+		txt := panicErrorTxt
+		if cmd.cfg.RecoverPanic != "" {
+			txt = strings.Replace(txt, "NewPanicError", cmd.cfg.RecoverPanic, -1)
+		}
+
+		io.WriteString(out, "\r\n")
+		io.WriteString(out, txt)
+		io.WriteString(out, "\r\n")
+	}
+	if cmd.outputObserver {
+		txt := observerTxt
+		if cmd.cfg.Observer != "" {
+			txt = strings.Replace(txt, "Observer", cmd.cfg.Observer, -1)
+		}
+
+		io.WriteString(out, "\r\n")
+		io.WriteString(out, txt)
+		io.WriteString(out, "\r\n")
+	}
 	return nil
 }
 
 func (cmd *ServerGenerator) genInitFunc(plugin Plugin, out io.Writer, swaggerParser *swag.Parser, file *astutil.File) error {
-	for _, ts := range file.TypeList {
+	typeList, err := instantiateTypes(file.TypeList)
+	if err != nil {
+		return err
+	}
+	schemes := readSecuritySchemes(file)
+	for _, ts := range typeList {
 		if ts.Struct == nil && ts.Interface == nil {
 			continue
 		}
@@ -268,15 +341,12 @@ func (cmd *ServerGenerator) genInitFunc(plugin Plugin, out io.Writer, swaggerPar
 				}
 			}
 		}
-		if ignore {
+		// 泛型的接口只能被嵌入到其它接口中或用 @gogen.instantiate 实例化
+		if ignore || ts.Node.TypeParams != nil {
 			io.WriteString(out, "\r\n// "+ts.Name+" is skipped")
 			continue
 		}
 
-		star := ""
-		if ts.Struct != nil {
-			star = "*"
-		}
 		methods, err := resolveMethods(swaggerParser, ts)
 		if err != nil {
 			return err
@@ -294,8 +364,32 @@ func (cmd *ServerGenerator) genInitFunc(plugin Plugin, out io.Writer, swaggerPar
 			continue
 		}
 
+		// 有 @Security 时 Init 函数多一个认证的参数
+		svcDeclaration := "svc " + serviceTypeName(ts)
+		if names := securityNames(methods); len(names) > 0 {
+			genAuthenticatorInterface(out, ts, names, schemes)
+			svcDeclaration += ", authenticator " + authenticatorName(ts)
+		}
+		for _, method := range methods {
+			if len(method.middlewares) > 0 {
+				svcDeclaration += ", " + plugin.NamedMiddlewaresDeclaration()
+				break
+			}
+		}
+		if cmd.cfg.Observer != "" {
+			svcDeclaration += ", observer " + cmd.cfg.Observer
+		}
+		if cmd.cfg.RouteInfo != "" {
+			cmd.genRouteInfos(out, ts, methods, optionalRoutePrefix)
+		}
+		routeIndex := 0
+
 		if optionalRoutePrefix != "" {
-			io.WriteString(out, "\r\n\r\nfunc Init"+ts.Name+"(mux "+plugin.PartyTypeName()+", enabledPrefix bool, svc "+star+ts.Name+", "+plugin.MiddlewaresDeclaration()+") {")
+			io.WriteString(out, "\r\n\r\nfunc Init"+ts.Name+"(mux "+plugin.PartyTypeName()+", enabledPrefix bool, "+svcDeclaration+", "+plugin.MiddlewaresDeclaration()+") {")
+			genMiddlewaresCheck(out, ts, methods)
+			if err := cmd.genRouteInfoMiddleware(out, plugin, methods); err != nil {
+				return err
+			}
 			if !plugin.IsPartyFluentStyle() {
 				io.WriteString(out, "\r\ninitFunc := func(mux "+plugin.PartyTypeName()+") {")
 			} else {
@@ -304,7 +398,11 @@ func (cmd *ServerGenerator) genInitFunc(plugin Plugin, out io.Writer, swaggerPar
 				io.WriteString(out, "\r\n\t}")
 			}
 		} else {
-			io.WriteString(out, "\r\n\r\nfunc Init"+ts.Name+"(mux "+plugin.PartyTypeName()+", svc "+star+ts.Name+", "+plugin.MiddlewaresDeclaration()+") {")
+			io.WriteString(out, "\r\n\r\nfunc Init"+ts.Name+"(mux "+plugin.PartyTypeName()+", "+svcDeclaration+", "+plugin.MiddlewaresDeclaration()+") {")
+			genMiddlewaresCheck(out, ts, methods)
+			if err := cmd.genRouteInfoMiddleware(out, plugin, methods); err != nil {
+				return err
+			}
 		}
 
 		if s := plugin.RenderWithMiddlewares("mux"); s != "" {
@@ -313,43 +411,101 @@ func (cmd *ServerGenerator) genInitFunc(plugin Plugin, out io.Writer, swaggerPar
 
 		for _, method := range methods {
 			// RenderFuncHeader 将输出： mux.Get("/allfiles", func(w http.ResponseWriter, r *http.Request) {
-			switch len(method.Operation.RouterProperties) {
-			case 0:
+			if len(method.Operation.RouterProperties) == 0 {
 				io.WriteString(out, "\r\n// "+method.Method.Name+": annotation is missing")
 				continue
 				// return errors.New(method.Method.PostionString() + ": RouterProperties is empty")
-			case 1:
-				break
-			default:
-				return errors.New(method.Method.PostionString() + ": RouterProperties is mult choices")
 			}
 
-			routeProps := method.Operation.RouterProperties[0]
-			if optionalRoutePrefix != "" {
-				if strings.HasPrefix(routeProps.Path, optionalRoutePrefix) {
-					routeProps.Path = strings.TrimPrefix(routeProps.Path, optionalRoutePrefix)
+			// body 输出处理函数的函数体, route 是路由路径的表达式
+			body := func(method *Method, route string) func(out io.Writer) error {
+				return func(out io.Writer) error {
+					ctx := &GenContext{
+						enableResultWrap:    cmd.enableResultWrap,
+						convertNS:           cmd.convertNamespace,
+						badArgument:         cmd.cfg.NewBadArgument,
+						errorMappings:       cmd.errorMappings,
+						securitySchemes:     schemes,
+						recoverPanic:        cmd.cfg.RecoverPanic,
+						websocketPingPeriod: cmd.websocketPingPeriod,
+						websocketWriteWait:  cmd.websocketWriteWait,
+						plugin:              plugin,
+						out:                 out,
+					}
+					if method.limits.maxBody > 0 {
+						ctx.plugin = bodyLimitedPlugin{Plugin: ctx.plugin}
+					}
+					if cmd.cfg.Observer != "" {
+						if err := renderObserveStart(out, plugin, &cmd.cfg, method, route); err != nil {
+							return err
+						}
+						ctx.observed = true
+						ctx.plugin = observedPlugin{Plugin: ctx.plugin}
+					}
+					return method.renderImpl(ctx)
 				}
 			}
 
-			if err := checkUrlValid(method, routeProps); err != nil {
-				return err
+			// 有多个 @Router 时处理函数只输出一次, 每个路由都注册它,
+			// 有 observer 时 observer.Start 需要路由的路径, 这时输出的是用路径创建处理函数的函数,
+			// 路由的默认状态码不同时(如 GET 和 POST)每个路由输出自己的处理函数
+			handlerName := ""
+			if len(method.Operation.RouterProperties) > 1 && !method.defaultStatusVaries() {
+				handlerName = "handle" + method.Method.Name
+				if cmd.cfg.Observer != "" {
+					io.WriteString(out, "\r\n"+handlerName+" := func(route string) "+plugin.HandlerTypeName()+" {\r\nreturn ")
+					if err := plugin.RenderHandlerFunc(out, method, body(method, "route")); err != nil {
+						return err
+					}
+					io.WriteString(out, "\r\n}")
+				} else {
+					io.WriteString(out, "\r\n"+handlerName+" := ")
+					if err := plugin.RenderHandlerFunc(out, method, body(method, "")); err != nil {
+						return err
+					}
+				}
 			}
-			fn := func(out io.Writer) error {
-				ctx := &GenContext{
-					enableResultWrap: cmd.enableResultWrap,
-					convertNS:        cmd.convertNamespace,
-					plugin:           plugin,
-					out:              out,
+
+			for _, routeProps := range method.Operation.RouterProperties {
+				routeMethod := method
+				if handlerName == "" {
+					copyed, props := *method, routeProps
+					copyed.route = &props
+					routeMethod = &copyed
+				}
+				observedRoute := strconv.Quote(routeProps.Path)
+				if optionalRoutePrefix != "" {
+					if strings.HasPrefix(routeProps.Path, optionalRoutePrefix) {
+						routeProps.Path = strings.TrimPrefix(routeProps.Path, optionalRoutePrefix)
+					}
 				}
-				err = method.renderImpl(ctx)
+
+				if err := checkUrlValid(method, routeProps); err != nil {
+					return err
+				}
+				// RouteInfo 作为路由的第一个中间件, 以便 @x-gogen-middleware 声明的中间件中也可以读取它
+				var middlewares []string
+				if cmd.cfg.RouteInfo != "" {
+					middlewares = append(middlewares, "withRouteInfo(&"+ts.Name+"Routes["+strconv.Itoa(routeIndex)+"])")
+				}
+				middlewares = append(middlewares, namedMiddlewares(method)...)
+				routeIndex++
+				handler := func(out io.Writer) error {
+					switch {
+					case handlerName == "":
+						return plugin.RenderHandlerFunc(out, routeMethod, body(routeMethod, observedRoute))
+					case cmd.cfg.Observer != "":
+						_, err := io.WriteString(out, handlerName+"("+observedRoute+")")
+						return err
+					default:
+						_, err := io.WriteString(out, handlerName)
+						return err
+					}
+				}
+				err := plugin.RenderFunc(out, routeMethod, routeProps, middlewares, handler)
 				if err != nil {
 					return err
 				}
-				return nil
-			}
-			err := plugin.RenderFunc(out, method, routeProps, fn)
-			if err != nil {
-				return err
 			}
 		}
 
@@ -367,6 +523,178 @@ func (cmd *ServerGenerator) genInitFunc(plugin Plugin, out io.Writer, swaggerPar
 	return nil
 }
 
+// genRouteInfos 输出 <Iface>Routes 变量, 每个路由对应一个 RouteInfo, 多个 @Router 时有多个,
+// Path 与注册的路由相同, 即去掉了 optional_route_prefix
+func (cmd *ServerGenerator) genRouteInfos(out io.Writer, ts *astutil.TypeSpec, methods []*Method, optionalRoutePrefix string) {
+	io.WriteString(out, "\r\n\r\nvar "+ts.Name+"Routes = []"+cmd.cfg.RouteInfo+"{")
+	for _, method := range methods {
+		for _, routeProps := range method.Operation.RouterProperties {
+			path := routeProps.Path
+			if optionalRoutePrefix != "" {
+				path = strings.TrimPrefix(path, optionalRoutePrefix)
+			}
+			io.WriteString(out, "\r\n\t{")
+			io.WriteString(out, "\r\n\t\tMethod: "+strconv.Quote(strings.ToUpper(routeProps.HTTPMethod))+",")
+			io.WriteString(out, "\r\n\t\tPath: "+strconv.Quote(path)+",")
+			if method.Operation.ID != "" {
+				io.WriteString(out, "\r\n\t\tOperationID: "+strconv.Quote(method.Operation.ID)+",")
+			}
+			if method.Operation.Summary != "" {
+				io.WriteString(out, "\r\n\t\tSummary: "+strconv.Quote(method.Operation.Summary)+",")
+			}
+			if len(method.Operation.Tags) > 0 {
+				io.WriteString(out, "\r\n\t\tTags: []string{")
+				for idx, tag := range method.Operation.Tags {
+					if idx > 0 {
+						io.WriteString(out, ", ")
+					}
+					io.WriteString(out, strconv.Quote(tag))
+				}
+				io.WriteString(out, "},")
+			}
+			if len(method.Operation.Security) > 0 {
+				io.WriteString(out, "\r\n\t\tSecurity: []map[string][]string{")
+				for _, requirement := range method.Operation.Security {
+					names := make([]string, 0, len(requirement))
+					for name := range requirement {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+
+					io.WriteString(out, "\r\n\t\t\t{")
+					for idx, name := range names {
+						if idx > 0 {
+							io.WriteString(out, ", ")
+						}
+						io.WriteString(out, strconv.Quote(name)+": "+scopesLiteral(requirement[name]))
+					}
+					io.WriteString(out, "},")
+				}
+				io.WriteString(out, "\r\n\t\t},")
+			}
+			if len(method.Operation.Parameters) > 0 {
+				io.WriteString(out, "\r\n\t\tParams: []"+routeInfoName(cmd.cfg.RouteInfo, "", "Param")+"{")
+				for _, param := range method.Operation.Parameters {
+					io.WriteString(out, "\r\n\t\t\t{Name: "+strconv.Quote(param.Name)+", In: "+strconv.Quote(param.In))
+					if param.Required {
+						io.WriteString(out, ", Required: true")
+					}
+					io.WriteString(out, "},")
+				}
+				io.WriteString(out, "\r\n\t\t},")
+			}
+			io.WriteString(out, "\r\n\t},")
+		}
+	}
+	io.WriteString(out, "\r\n}")
+}
+
+// genMiddlewaresCheck 在 Init 函数的开头检查 @x-gogen-middleware 声明的中间件是否都在 middlewares 中,
+// 以便在启动时而不是在请求时发现缺少的中间件
+func genMiddlewaresCheck(out io.Writer, ts *astutil.TypeSpec, methods []*Method) {
+	var names []string
+	exists := map[string]bool{}
+	for _, method := range methods {
+		for _, name := range method.middlewares {
+			if !exists[name] {
+				exists[name] = true
+				names = append(names, strconv.Quote(name))
+			}
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	io.WriteString(out, "\r\n\tfor _, name := range []string{"+strings.Join(names, ", ")+"} {")
+	io.WriteString(out, "\r\n\t\tif middlewares[name] == nil {")
+	io.WriteString(out, "\r\n\t\t\tpanic(\"Init"+ts.Name+": middleware '\" + name + \"' isnot found in the middlewares\")")
+	io.WriteString(out, "\r\n\t\t}")
+	io.WriteString(out, "\r\n\t}")
+}
+
+// genRouteInfoMiddleware 在 Init 函数的开头输出 withRouteInfo 函数, 它返回将 RouteInfo 放在请求的 context 中的中间件
+func (cmd *ServerGenerator) genRouteInfoMiddleware(out io.Writer, plugin Plugin, methods []*Method) error {
+	if cmd.cfg.RouteInfo == "" {
+		return nil
+	}
+	hasRoute := false
+	for _, method := range methods {
+		if len(method.Operation.RouterProperties) > 0 {
+			hasRoute = true
+		}
+	}
+	if !hasRoute {
+		return nil
+	}
+
+	io.WriteString(out, "\r\n\twithRouteInfo := func(info *"+cmd.cfg.RouteInfo+") "+plugin.MiddlewareTypeName()+" {")
+	io.WriteString(out, "\r\n\t\treturn ")
+	err := plugin.RenderMiddlewareFunc(out, func(out io.Writer) error {
+		stdctx, _ := plugin.GetSpecificTypeArgument("context.Context")
+		return plugin.RenderSetContext(out, routeInfoName(cmd.cfg.RouteInfo, "With", "")+"("+stdctx+", info)")
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "\r\n\t}")
+	return err
+}
+
+// producesCSV 判断包中是否有方法用 @Produce 声明了 csv
+func producesCSV(file *astutil.File) bool {
+	return hasAnnotation(file, func(fields []string, line string) bool {
+		return strings.EqualFold(fields[0], "@Produce") &&
+			strings.Contains(strings.ToLower(line), "csv")
+	})
+}
+
+// hasWebsocketMethod 判断包中是否有方法用 @x-gogen-websocket 声明为 websocket
+func hasWebsocketMethod(file *astutil.File) bool {
+	return hasAnnotation(file, func(fields []string, line string) bool {
+		return strings.EqualFold(fields[0], "@x-gogen-websocket") &&
+			strings.EqualFold(fields[1], "true")
+	})
+}
+
+// hasAnnotation 判断包中是否有至少两个字段并且与 match 匹配的注释行
+func hasAnnotation(file *astutil.File, match func(fields []string, line string) bool) bool {
+	matchFile := func(f *astutil.File) bool {
+		if f.AstFile == nil {
+			return false
+		}
+		for _, comment := range f.AstFile.Comments {
+			for _, line := range strings.Split(comment.Text(), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 && match(fields, line) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if matchFile(file) {
+		return true
+	}
+	if file.Package == nil {
+		return false
+	}
+	for i := 0; i < file.Package.FileCount(); i++ {
+		f, err := file.Package.GetFileByIndex(i)
+		if err == nil && f != file && matchFile(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeInfoName 返回与 RouteInfo 相关的名称, 如 RouteInfoParam 和 WithRouteInfo, RouteInfo 可以是其它包中的类型
+func routeInfoName(typeName, prefix, suffix string) string {
+	if idx := strings.LastIndex(typeName, "."); idx >= 0 {
+		return typeName[:idx+1] + prefix + typeName[idx+1:] + suffix
+	}
+	return prefix + typeName + suffix
+}
+
 func ParseFile(ctx *astutil.Context, filename string) (*astutil.File, error) {
 	if ctx == nil {
 		ctx = astutil.NewContext(nil)
@@ -427,3 +755,113 @@ const httpCodeWithTxt = `func httpCodeWith(err error, statusCode ...int) int {
   }
   return http.StatusInternalServerError
 }`
+
+const problemTxt = `// Problem 是 RFC 7807 中定义的错误格式, Operation 和 Param 是扩展的成员,
+// 只有 4xx 的错误才将错误信息放在 Detail 中, 以免将服务端的内部错误返回给客户端
+type Problem struct {
+	Type      string ` + "`json:\"type\"`" + `
+	Title     string ` + "`json:\"title\"`" + `
+	Status    int    ` + "`json:\"status\"`" + `
+	Detail    string ` + "`json:\"detail,omitempty\"`" + `
+	Instance  string ` + "`json:\"instance,omitempty\"`" + `
+	Operation string ` + "`json:\"operation,omitempty\"`" + `
+	Param     string ` + "`json:\"param,omitempty\"`" + `
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+func (p *Problem) HTTPCode() int {
+	return p.Status
+}
+
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, operation string, err error, param ...string) {
+	problem := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  r.URL.RequestURI(),
+		Operation: operation,
+	}
+	if err != nil && status >= 400 && status < 500 {
+		problem.Detail = err.Error()
+	}
+	if len(param) > 0 {
+		problem.Param = param[0]
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}`
+
+const routeInfoTxt = `// RouteInfo 是路由的元数据, 生成的处理函数会将它放在请求的 context 中
+type RouteInfo struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Tags        []string
+	Security    []map[string][]string
+	Params      []RouteInfoParam
+}
+
+// RouteInfoParam 是路由的参数, In 可以是 path, query, header, body 和 formData
+type RouteInfoParam struct {
+	Name     string
+	In       string
+	Required bool
+}
+
+type routeInfoKey struct{}
+
+func WithRouteInfo(ctx context.Context, info *RouteInfo) context.Context {
+	return context.WithValue(ctx, routeInfoKey{}, info)
+}
+
+func RouteInfoFromContext(ctx context.Context) (*RouteInfo, bool) {
+	info, ok := ctx.Value(routeInfoKey{}).(*RouteInfo)
+	return info, ok
+}`
+
+const observerTxt = `// Observer 用于观测生成的处理函数, 如统计每个操作的耗时和错误次数或者创建 trace span,
+// Init 函数的 observer 参数为 nil 时不会调用它
+type Observer interface {
+	// Start 在处理请求之前被调用, operation 为方法的全名, route 为 @Router 中的路径,
+	// 返回的 context 会替换请求的 context, 返回的 Observation 不能为 nil
+	Start(ctx context.Context, operation, route string) (context.Context, Observation)
+}
+
+// Observation 是对一次请求的观测
+type Observation interface {
+	// BindFailed 在参数绑定失败或请求被拒绝(如认证失败, 不支持的 Content-Type, 请求体过大)时被调用,
+	// param 为参数名, 认证失败时为 "Authorization", 不支持的 Content-Type 时为 "Content-Type"
+	BindFailed(param string, err error)
+	// ServiceFailed 在服务方法返回错误时被调用
+	ServiceFailed(err error)
+	// Written 在处理函数返回时被调用, statusCode 为响应的状态码
+	Written(statusCode int)
+}`
+
+const panicErrorTxt = `// PanicError 是处理请求时发生的 panic, Value 是 panic 的值, Stack 是发生 panic 时的调用栈,
+// 它们都不会返回给客户端, Error() 中也不包含它们, 记录日志时请直接读取它们
+type PanicError struct {
+	Operation string      ` + "`json:\"operation\"`" + `
+	Value     interface{} ` + "`json:\"-\"`" + `
+	Stack     []byte      ` + "`json:\"-\"`" + `
+}
+
+func (e *PanicError) Error() string {
+	return e.Operation + ": panic"
+}
+
+func (e *PanicError) HTTPCode() int {
+	return http.StatusInternalServerError
+}
+
+func NewPanicError(operation string, value interface{}) error {
+	return &PanicError{Operation: operation, Value: value, Stack: debug.Stack()}
+}`
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"go/ast"
 	"go/token"
+	"io"
 	"reflect"
 	"strings"
 	"unicode"
@@ -171,7 +172,6 @@ type ConvertFunc struct {
 	HasRetError bool
 }
 
-
 var convertParamTypes []string
 
 var ConvertHook func(isArray bool, paramType string) *ConvertFunc
@@ -190,12 +190,12 @@ func selectConvert(convertNS string, isArray bool, resultType, paramType string)
 		}
 	}
 
-	for  _, name := range convertParamTypes {
+	for _, name := range convertParamTypes {
 		if name == paramType {
 			if isArray {
-				return "Parse"+name+"(%s)", false, true, nil
+				return "Parse" + name + "(%s)", false, true, nil
 			}
-			return "Parse"+name+"(%s)", false, true, nil
+			return "Parse" + name + "(%s)", false, true, nil
 		}
 	}
 	dot := strings.IndexByte(paramType, '.')
@@ -203,12 +203,12 @@ func selectConvert(convertNS string, isArray bool, resultType, paramType string)
 		ns := paramType[:dot]
 		typeName := paramType[dot+1:]
 
-		for  _, name := range convertParamTypes {
+		for _, name := range convertParamTypes {
 			if name == typeName {
 				if isArray {
-					return ns + ".Parse"+name+"(%s)", false, true, nil
+					return ns + ".Parse" + name + "(%s)", false, true, nil
 				}
-				return ns + ".Parse"+name+"(%s)", false, true, nil
+				return ns + ".Parse" + name + "(%s)", false, true, nil
 			}
 		}
 	}
@@ -310,6 +310,328 @@ func selectConvert(convertNS string, isArray bool, resultType, paramType string)
 	}
 }
 
+// hasMethod 判断类型（或它的指针）是否声明了指定名称的方法
+func hasMethod(typ astutil.Type, name string) bool {
+	if t := typ.PtrElemType(); t.IsValid() {
+		typ = t
+	}
+	if typ.File == nil {
+		return false
+	}
+	switch typ.Expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+	default:
+		return false
+	}
+	if typ.IsBasicType(false) {
+		return false
+	}
+
+	ts, err := typ.ToTypeSpec(false)
+	if err != nil || ts == nil || ts.File == nil || ts.Interface != nil {
+		return false
+	}
+	if ts.MethodByName(name) != nil {
+		return true
+	}
+
+	files := []*astutil.File{ts.File}
+	if ts.File.Package != nil {
+		if all, err := ts.File.Package.LoadAll(); err == nil {
+			files = all
+		}
+	}
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		for _, m := range file.MethodListByType[ts.Name] {
+			if m.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTextUnmarshaler(typ astutil.Type) bool {
+	return hasMethod(typ, "UnmarshalText")
+}
+
+// enumValues 返回包中以 typ 为类型声明的常量, 如
+//
+//	type Status string
+//
+//	const (
+//	  StatusActive   Status = "active"
+//	  StatusDisabled Status = "disabled"
+//	)
+func enumValues(typ astutil.Type) []string {
+	if typ.File == nil || !typ.IsBasicType(true) || typ.IsBasicType(false) {
+		return nil
+	}
+	var ns string
+	switch expr := typ.Expr.(type) {
+	case *ast.Ident:
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok {
+			ns = x.Name + "."
+		}
+	default:
+		return nil
+	}
+
+	ts, err := typ.ToTypeSpec(false)
+	if err != nil || ts == nil || ts.File == nil {
+		return nil
+	}
+
+	files := []*astutil.File{ts.File}
+	if ts.File.Package != nil {
+		if all, err := ts.File.Package.LoadAll(); err == nil {
+			files = all
+		}
+	}
+
+	var values []string
+	for _, file := range files {
+		if file == nil || file.AstFile == nil {
+			continue
+		}
+		for _, decl := range file.AstFile.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+
+			// 没有类型和值的常量沿用上一行的类型, 如 iota
+			var typeName string
+			for _, spec := range gd.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				if vs.Type != nil {
+					typeName = ""
+					if ident, ok := vs.Type.(*ast.Ident); ok {
+						typeName = ident.Name
+					}
+				} else if len(vs.Values) > 0 {
+					typeName = ""
+				}
+				if typeName != ts.Name {
+					continue
+				}
+				for _, name := range vs.Names {
+					if name.Name == "_" {
+						continue
+					}
+					if ns != "" && !name.IsExported() {
+						continue
+					}
+					values = append(values, ns+name.Name)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// selectTextConvert 为实现了 encoding.TextUnmarshaler 的类型生成转换函数
+func selectTextConvert(isArray bool, typeStr string) string {
+	if isArray {
+		return "func(ss []string) ([]" + typeStr + ", error) {" +
+			"\r\n\tresults := make([]" + typeStr + ", 0, len(ss))" +
+			"\r\n\tfor _, s := range ss {" +
+			"\r\n\t\tvar v " + typeStr +
+			"\r\n\t\tif err := v.UnmarshalText([]byte(s)); err != nil {" +
+			"\r\n\t\t\treturn nil, err" +
+			"\r\n\t\t}" +
+			"\r\n\t\tresults = append(results, v)" +
+			"\r\n\t}" +
+			"\r\n\treturn results, nil" +
+			"\r\n}(%s)"
+	}
+	return "func(s string) (" + typeStr + ", error) {" +
+		"\r\n\tvar v " + typeStr +
+		"\r\n\terr := v.UnmarshalText([]byte(s))" +
+		"\r\n\treturn v, err" +
+		"\r\n}(%s)"
+}
+
+// isStreamResult 判断返回参数是否为流, 支持 (io.Reader, error), (io.ReadCloser, error),
+// 以及带文件名的 (io.Reader, string, error) 和 (io.ReadCloser, string, error)
+func isStreamResult(method *Method) bool {
+	results := method.Method.Results.List
+	if len(results) != 2 && len(results) != 3 {
+		return false
+	}
+	if typeStr := results[0].Type().ToLiteral(); typeStr != "io.Reader" && typeStr != "io.ReadCloser" {
+		return false
+	}
+	if len(results) == 3 && results[1].Type().ToLiteral() != "string" {
+		return false
+	}
+	return results[len(results)-1].Type().IsErrorType()
+}
+
+// eventStreamElemType 当返回参数为 (<-chan T, error) 时返回 T 的类型字符串
+func eventStreamElemType(method *Method) (string, bool) {
+	results := method.Method.Results.List
+	if len(results) != 2 || !results[1].Type().IsErrorType() {
+		return "", false
+	}
+	ch, ok := results[0].Type().Expr.(*ast.ChanType)
+	if !ok || ch.Dir == ast.SEND {
+		return "", false
+	}
+	return astutil.ToString(ch.Value), true
+}
+
+// isEventStreamProduce 判断 @Produce 中是否有 text/event-stream, 这时 iter.Seq2 按 SSE 输出
+func isEventStreamProduce(method *Method) bool {
+	for _, mimeType := range method.Operation.Produces {
+		if mimeType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// renderBuildTag 输出生成的文件的 build 约束, 源文件导入了 iter 时(用 iter.Seq2 作为返回值)
+// 生成的代码中有 range-over-func, 所以还要加上 go1.23
+func renderBuildTag(out io.Writer, buildTag string, file *astutil.File) {
+	goBuild, plusBuild := buildTag, buildTag
+	if importsIter(file) {
+		if buildTag == "" {
+			goBuild, plusBuild = "go1.23", "go1.23"
+		} else {
+			goBuild, plusBuild = buildTag+" && go1.23", buildTag+",go1.23"
+		}
+	}
+	if goBuild == "" {
+		return
+	}
+	io.WriteString(out, "//go:build ")
+	io.WriteString(out, goBuild)
+	io.WriteString(out, "\r\n")
+	io.WriteString(out, "// +build ")
+	io.WriteString(out, plusBuild)
+	io.WriteString(out, "\r\n")
+	io.WriteString(out, "\r\n")
+}
+
+func importsIter(file *astutil.File) bool {
+	if file == nil || file.AstFile == nil {
+		return false
+	}
+	for _, spec := range file.AstFile.Imports {
+		if spec.Path != nil && spec.Path.Value == "\"iter\"" {
+			return true
+		}
+	}
+	return false
+}
+
+// seqElemType 当返回参数为 iter.Seq2[T, error] 或 (iter.Seq2[T, error], error) 时返回 T 的类型字符串
+func seqElemType(method *Method) (string, bool) {
+	results := method.Method.Results.List
+	if len(results) != 1 && len(results) != 2 {
+		return "", false
+	}
+	if len(results) == 2 && !results[1].Type().IsErrorType() {
+		return "", false
+	}
+	expr, ok := results[0].Type().Expr.(*ast.IndexListExpr)
+	if !ok || len(expr.Indices) != 2 ||
+		astutil.ToString(expr.X) != "iter.Seq2" ||
+		astutil.ToString(expr.Indices[1]) != "error" {
+		return "", false
+	}
+	return astutil.ToString(expr.Indices[0]), true
+}
+
+// yieldParam 当方法只返回 error 且有一个 func(T) error 参数时返回该参数的位置和 T 的类型字符串,
+// 没有时返回 -1
+func yieldParam(method *Method) (int, string) {
+	results := method.Method.Results.List
+	if len(results) != 1 || !results[0].Type().IsErrorType() {
+		return -1, ""
+	}
+	for idx := range method.Method.Params.List {
+		fn, ok := method.Method.Params.List[idx].Type().Expr.(*ast.FuncType)
+		if !ok || fn.Params == nil || fn.Results == nil ||
+			len(fn.Params.List) != 1 || len(fn.Params.List[0].Names) > 1 ||
+			len(fn.Results.List) != 1 || astutil.ToString(fn.Results.List[0].Type) != "error" {
+			continue
+		}
+		return idx, astutil.ToString(fn.Params.List[0].Type)
+	}
+	return -1, ""
+}
+
+// websocketInParam 返回 websocket 方法中 <-chan T 参数的位置和 T 的类型字符串, 没有时返回 -1
+func websocketInParam(method *Method) (int, string) {
+	for idx := range method.Method.Params.List {
+		ch, ok := method.Method.Params.List[idx].Type().Expr.(*ast.ChanType)
+		if !ok || ch.Dir == ast.SEND {
+			continue
+		}
+		return idx, astutil.ToString(ch.Value)
+	}
+	return -1, ""
+}
+
+// responseHeaderResults 返回绑定到响应头的返回参数, key 为返回参数的位置, value 为头的名称,
+// 返回参数的名称与 @Header 中的名称相同(忽略大小写, - , _ 和 X- 前缀)时绑定到该头, *http.Cookie 类型的返回参数绑定到 Set-Cookie
+func responseHeaderResults(method *Method) map[int]string {
+	var headers []string
+	if method.Operation.Responses != nil {
+		for _, code := range successStatusCodes(method) {
+			for name := range method.Operation.Responses.StatusCodeResponses[code].Headers {
+				headers = append(headers, name)
+			}
+		}
+	}
+
+	normalize := func(name string) string {
+		name = strings.TrimPrefix(strings.ToLower(name), "x-")
+		return strings.NewReplacer("-", "", "_", "").Replace(name)
+	}
+
+	var results map[int]string
+	for idx, result := range method.Method.Results.List {
+		if result.Type().IsErrorType() {
+			continue
+		}
+		if isCookieType(result.Type()) {
+			if results == nil {
+				results = map[int]string{}
+			}
+			results[idx] = "Set-Cookie"
+			continue
+		}
+		if result.Name == "" {
+			continue
+		}
+		for _, name := range headers {
+			if normalize(name) == normalize(result.Name) {
+				if results == nil {
+					results = map[int]string{}
+				}
+				results[idx] = name
+				break
+			}
+		}
+	}
+	return results
+}
+
+func isCookieType(typ astutil.Type) bool {
+	typeStr := typ.ToLiteral()
+	return typeStr == "*http.Cookie" || typeStr == "http.Cookie"
+}
+
 func UnderscoreSimple(name string) string {
 	return strings.Replace(inflect.Underscore(name), "_i_d", "_id", -1)
 }
@@ -334,10 +656,10 @@ func toSnakeCase(in string) string {
 	return Underscore(in)
 }
 
-func FieldNameEqual(name1,  name2 string) bool {
+func FieldNameEqual(name1, name2 string) bool {
 	return strings.EqualFold(name1, name2) ||
-				strings.EqualFold(toSnakeCase(name1), name2) ||
-				strings.EqualFold(Singularize(name1), name2) 
+		strings.EqualFold(toSnakeCase(name1), name2) ||
+		strings.EqualFold(Singularize(name1), name2)
 }
 
 func toLowerCamelCase(in string) string {
@@ -387,6 +709,8 @@ func zeroValueLiteral(typ astutil.Type) string {
 		return "nil"
 	case *ast.MapType:
 		return "nil"
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return genericZeroValueLiteral(typ)
 	}
 
 	if typ.IsStringType(true) {
@@ -400,6 +724,44 @@ func zeroValueLiteral(typ astutil.Type) string {
 	return "0"
 }
 
+// isGenericInstance 判断类型是否为泛型的实例, 如 Page[User],
+// astutil 中的 IsStringType 之类的函数不支持它们, 调用前要先判断
+func isGenericInstance(typ astutil.Type) bool {
+	switch typ.Expr.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+// genericBaseType 返回泛型实例的泛型类型, 如 Page[User] 返回 Page
+func genericBaseType(typ astutil.Type) astutil.Type {
+	switch expr := typ.Expr.(type) {
+	case *ast.IndexExpr:
+		return astutil.Type{File: typ.File, Expr: expr.X}
+	case *ast.IndexListExpr:
+		return astutil.Type{File: typ.File, Expr: expr.X}
+	}
+	return typ
+}
+
+func genericZeroValueLiteral(typ astutil.Type) string {
+	ts, err := genericBaseType(typ).ToTypeSpec(false)
+	if err != nil || ts.Struct != nil {
+		return typ.ToLiteral() + "{}"
+	}
+	if ts.Interface != nil {
+		return "nil"
+	}
+	switch ts.Node.Type.(type) {
+	case *ast.ArrayType, *ast.MapType, *ast.StarExpr, *ast.ChanType, *ast.FuncType:
+		return "nil"
+	case *ast.Ident, *ast.SelectorExpr:
+		return zeroValueLiteral(astutil.Type{File: ts.File, Expr: ts.Node.Type})
+	}
+	return typ.ToLiteral() + "{}"
+}
+
 func isExtendEntire(param *spec.Parameter) bool {
 	s, ok := param.Extensions.GetString("x-gogen-entire-body")
 	if !ok {
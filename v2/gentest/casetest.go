@@ -1,12 +1,83 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"io"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/runner-mei/gogen/v2/gentest/models"
 )
 
 type Options struct {}
 
+type TextID int
+
+func (id *TextID) UnmarshalText(text []byte) error {
+	i, err := strconv.Atoi(strings.TrimPrefix(string(text), "id-"))
+	if err != nil {
+		return err
+	}
+	*id = TextID(i)
+	return nil
+}
+
+func (id TextID) MarshalText() ([]byte, error) {
+	return []byte("id-" + strconv.Itoa(int(id))), nil
+}
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusDisabled Status = "disabled"
+)
+
+type Level int
+
+const (
+	LevelLow Level = iota + 1
+	LevelMiddle
+	LevelHigh
+)
+
+type HeaderArgs struct {
+	Name      string `json:"name"`
+	RequestID string `json:"request_id" header:"X-Request-Id"`
+	Retry     *int   `json:"retry,omitempty" header:"X-Retry"`
+}
+
+type DeepQuery struct {
+	Name  string              `json:"name"`
+	Items []TypeInfo          `json:"items"`
+	Attrs map[string]TypeInfo `json:"attrs"`
+}
+
+type DeepFilter struct {
+	Name string        `json:"name"`
+	Sub  DeepSubFilter `json:"sub"`
+}
+
+type DeepSubFilter struct {
+	Tags map[string][]TypeInfo `json:"tags"`
+}
+
+var ErrRecordNotFound = errors.New("record not found")
+
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
 // @http.Client(name="TestClient", ref="true")
 type CaseSvc interface {
 
@@ -384,9 +455,481 @@ type CaseSvc interface {
 	// @Router /TestResult3 [get]
 	TestResult3() (code *int, data *string, err error)
 
+	// @Summary TestCollectionFormat
+	// @ID TestCollectionFormat
+	// @Param   ids      query   []int64   false  "ids" collectionFormat(csv)
+	// @Param   names    query   []string  false  "names" collectionFormat(pipes)
+	// @Param   tags     query   []string  false  "tags" collectionFormat(multi)
+	// @Param   codes    query   []string  false  "codes" collectionFormat(tsv)
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /collection_format [get]
+	TestCollectionFormat(ids []int64, names []string, tags []string, codes []string) error
+
+	// @Summary TestDeepObject
+	// @ID TestDeepObject
+	// @Param   query      query   DeepQuery   true  "query"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /deep_object [get]
+	TestDeepObject(query DeepQuery) error
+
+	// @Summary TestDeepObjectNested
+	// @ID TestDeepObjectNested
+	// @Param   filter      query   DeepFilter   true  "filter"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /deep_object_nested [get]
+	TestDeepObjectNested(filter DeepFilter) error
+
+	// @Summary TestDeepObjectForm
+	// @ID TestDeepObjectForm
+	// @Param   query      formData   DeepQuery   true  "query"
+	// @Accept  x-www-form-urlencoded
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /deep_object_form [post]
+	TestDeepObjectForm(query DeepQuery) error
+
+	// @Summary TestTextParam
+	// @ID TestTextParam
+	// @Param   id      query   string     true  "id"
+	// @Param   values  query   []string   false  "values"
+	// @Param   pid     query   string     false  "pid"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /text_param [get]
+	TestTextParam(id TextID, values []TextID, pid *TextID) error
+
+	// @Summary TestEnumParam
+	// @ID TestEnumParam
+	// @Param   status    path    string     true  "status"
+	// @Param   level     query   int        false  "level"
+	// @Param   level_list    query   []int      false  "level list"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /enum_param/{status} [get]
+	TestEnumParam(status Status, level Level, levelList []Level) error
+
+	// @Summary TestHeaderParam
+	// @ID TestHeaderParam
+	// @Param   ts        header   int64      false  "ts" extensions(x-gogen-header=X-Request-Ts)
+	// @Param   langs     header   []string   false  "langs" extensions(x-gogen-header=Accept-Language)
+	// @Param   ids       header   []int64    false  "ids" extensions(x-gogen-header=X-Ids)
+	// @Param   since     header   string     false  "since" extensions(x-gogen-header=X-Since)
+	// @Param   args      query    HeaderArgs false  "args"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /header_param [get]
+	TestHeaderParam(ts int64, langs []string, ids []int64, since *time.Time, args HeaderArgs) error
+
+	// @Summary TestNegotiateBody
+	// @ID TestNegotiateBody
+	// @Param   typ      body   TypeInfo   true  "type"
+	// @Accept  json,xml,application/x-msgpack
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /negotiate_body [post]
+	TestNegotiateBody(typ TypeInfo) error
+
+	// @Summary TestNegotiateProduce
+	// @ID TestNegotiateProduce
+	// @Accept  json
+	// @Produce  json,xml,text/csv,application/x-msgpack
+	// @Success 200 {array} TypeInfo	"ok"
+	// @Router /negotiate_produce [get]
+	TestNegotiateProduce() ([]TypeInfo, error)
+
+	// @Summary TestProduceBinary
+	// @ID TestProduceBinary
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  octet-stream
+	// @Success 200 {string} string	"ok"
+	// @Router /produce_binary/{id} [get]
+	TestProduceBinary(id int64) ([]byte, error)
+
+	// @Summary TestProduceMappedError
+	// @ID TestProduceMappedError
+	// @Accept  json
+	// @Produce  xml
+	// @x-gogen-error ErrRecordNotFound 404
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /produce_mapped_error [get]
+	TestProduceMappedError() (*TypeInfo, error)
+
+	// @Summary TestStreamResult
+	// @ID TestStreamResult
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  text/csv
+	// @Success 200 {string} string	"ok"
+	// @Router /stream_result/{id} [get]
+	TestStreamResult(id int64) (io.ReadCloser, error)
+
+	// @Summary TestDownload
+	// @ID TestDownload
+	// @Param   name      path   string   true  "name"
+	// @Accept  json
+	// @Success 200 {string} string	"ok"
+	// @Router /download/{name} [get]
+	TestDownload(name string) (io.Reader, string, error)
+
+	// @Summary TestWatch
+	// @ID TestWatch
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  text/event-stream
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /watch/{id} [get]
+	TestWatch(ctx context.Context, id int64) (<-chan TypeInfo, error)
+
+	// @Summary TestListYield
+	// @ID TestListYield
+	// @Param   name      query   string   false  "name"
+	// @Accept  json
+	// @Produce  application/x-ndjson
+	// @Success 200 {array} TypeInfo	"ok"
+	// @Router /list_yield [get]
+	TestListYield(ctx context.Context, name string, yield func(TypeInfo) error) error
+
+	// @Summary TestSession
+	// @ID TestSession
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-websocket true
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /session/{id} [get]
+	TestSession(ctx context.Context, id int64, in <-chan Options) (<-chan TypeInfo, error)
+
+	// @Summary TestSubscribe
+	// @ID TestSubscribe
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-websocket true
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /subscribe [get]
+	TestSubscribe(ctx context.Context) (<-chan TypeInfo, error)
+
+	// @Summary TestSuccessAccepted
+	// @ID TestSuccessAccepted
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Success 202 {object} TypeInfo	"accepted"
+	// @Router /success_accepted/{id} [put]
+	TestSuccessAccepted(id int64) (*TypeInfo, error)
+
+	// @Summary TestSuccessNoContent
+	// @ID TestSuccessNoContent
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Success 204 "no content"
+	// @Router /success_no_content/{id} [delete]
+	TestSuccessNoContent(id int64) error
+
+	// @Summary TestSuccessOverride
+	// @ID TestSuccessOverride
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-status-code 201
+	// @Success 200 {string} string	"ok"
+	// @Router /success_override/{id} [post]
+	TestSuccessOverride(id int64) error
+
+	// @Summary TestResponseHeader
+	// @ID TestResponseHeader
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Header 200 {string} ETag "etag"
+	// @Header 200 {integer} X-Total-Count "total"
+	// @Router /response_header/{id} [get]
+	TestResponseHeader(id int64) (item *TypeInfo, etag string, totalCount int64, err error)
+
+	// @Summary TestResponseCookie
+	// @ID TestResponseCookie
+	// @Param   name      query   string   true  "name"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Header 200 {string} Set-Cookie "session"
+	// @Router /response_cookie [post]
+	TestResponseCookie(name string) (session *http.Cookie, err error)
+
+	// @Summary TestErrorMapping
+	// @ID TestErrorMapping
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-error ErrRecordNotFound 404
+	// @x-gogen-error *ValidationError 422
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Failure 404 {string} string	"not found"
+	// @Failure 422 {string} string	"invalid"
+	// @Router /error_mapping/{id} [get]
+	TestErrorMapping(id int64) (*TypeInfo, error)
+
+	// @Summary TestErrorMappingNoResult
+	// @ID TestErrorMappingNoResult
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-error ErrRecordNotFound 404
+	// @Router /error_mapping/{id} [delete]
+	TestErrorMappingNoResult(id int64) error
+
+	// @Summary TestMultiRoutes
+	// @ID TestMultiRoutes
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /multi_routes/{id} [get]
+	// @Router /v1/multi_routes/{id} [get]
+	TestMultiRoutes(id int64) (*TypeInfo, error)
+
+	// @Summary TestMultiMethods
+	// @ID TestMultiMethods
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Router /multi_methods/{id} [get]
+	// @Router /multi_methods/{id} [post]
+	TestMultiMethods(id int64) (*TypeInfo, error)
+
+	// @Summary TestMultiRoutesPrimary
+	// @ID TestMultiRoutesPrimary
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-primary-route "/v2/multi_routes/{id}"
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /v1/multi_routes/{id} [put]
+	// @Router /v2/multi_routes/{id} [put]
+	TestMultiRoutesPrimary(id int64) (*TypeInfo, error)
+
 	// Misc() string
 }
 
+type HealthChecker interface {
+	// @Summary Health
+	// @ID Health
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /health [get]
+	Health() (string, error)
+}
+
+type CRUDService[T any] interface {
+	// @Summary Get
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Router /items/{id} [get]
+	Get(id int64) (*T, error)
+
+	// @Summary List
+	// @Param   offset      query   int   false  "offset"
+	// @Param   limit       query   int   false  "limit"
+	// @Accept  json
+	// @Produce  json
+	// @Router /items [get]
+	List(offset, limit int) ([]T, error)
+}
+
+// @gogen.optional_route_prefix /embedded
+type EmbeddedSvc interface {
+	HealthChecker
+	CRUDService[TypeInfo]
+	models.RequestReader
+
+	// @Summary Health 被外层的方法覆盖
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /embedded/health [get]
+	Health() (string, error)
+}
+
+type Page[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+}
+
+// @gogen.instantiate Store[TypeInfo] as TypeInfoStore
+// @gogen.instantiate Store[models.Request] as RequestStore
+type Store[T any] interface {
+	// @Summary Get
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Router /store/{id} [get]
+	Get(id int64) (*T, error)
+
+	// @Summary Query
+	// @Param   offset      query   int   false  "offset"
+	// @Param   limit       query   int   false  "limit"
+	// @Accept  json
+	// @Produce  json
+	// @Router /store [get]
+	Query(offset, limit int) (*Page[T], error)
+
+	// @Summary Create
+	// @Param   item      body   object   true  "item"
+	// @Accept  json
+	// @Produce  json
+	// @Router /store [post]
+	Create(item *T) (int64, error)
+
+	// @Summary Update
+	// @Param   id      path   int64   true  "id"
+	// @Param   item      body   object   true  "item"
+	// @Accept  json
+	// @Produce  json
+	// @Router /store/{id} [put]
+	Update(id int64, item T) error
+
+	// @Summary Replace
+	// @Param   items      body   object   true  "items"
+	// @Accept  json
+	// @Produce  json
+	// @Router /store [put]
+	Replace(items Page[T]) (Page[T], error)
+}
+
+type contextKey struct{ name string }
+
+var (
+	TenantKey      = &contextKey{name: "tenant"}
+	CurrentUserKey = &contextKey{name: "user"}
+)
+
+type ContextValueSvc interface {
+	// @Summary Get
+	// @x-gogen-context tenantID TenantKey
+	// @x-gogen-context currentUser CurrentUserKey
+	// @Param   id      path   int   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Router /ctxvalues/{id} [get]
+	Get(tenantID string, currentUser *models.Request, id int) (string, error)
+
+	// @Summary Set
+	// @x-gogen-context tenantID TenantKey
+	// @Param   value      body   string   true  "value"
+	// @Accept  json
+	// @Produce  json
+	// @Router /ctxvalues [post]
+	Set(ctx context.Context, tenantID string, value string) error
+}
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey QueryKeyAuth
+// @in query
+// @name api_key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
+// @securityDefinitions.basic BasicAuth
+
+// @securityDefinitions.oauth2.application OAuth2Application
+// @tokenUrl https://example.com/oauth/token
+// @scope.read Grants read access
+// @scope.write Grants write access
+
+type SecuritySvc interface {
+	// @Summary Public
+	// @Accept  json
+	// @Produce  json
+	// @Router /security/public [get]
+	Public() (string, error)
+
+	// @Summary ByApiKey
+	// @Security ApiKeyAuth
+	// @Param   id      path   int   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Router /security/apikey/{id} [get]
+	ByApiKey(id int) (string, error)
+
+	// @Summary ByAny 多个 @Security 之间是或的关系
+	// @Security BearerAuth
+	// @Security QueryKeyAuth
+	// @Security BasicAuth
+	// @Accept  json
+	// @Produce  json
+	// @Router /security/any [get]
+	ByAny() (string, error)
+
+	// @Summary ByAll 同一个 @Security 中的认证方式是与的关系
+	// @Security ApiKeyAuth || OAuth2Application[read, write]
+	// @Accept  json
+	// @Produce  json
+	// @Router /security/all [post]
+	ByAll() error
+
+	// @Summary ByCustom 没有声明的认证方式
+	// @Security CustomAuth[admin]
+	// @Accept  json
+	// @Produce  json
+	// @Router /security/custom [get]
+	ByCustom() error
+}
+
+type MiddlewareSvc interface {
+	// @Summary List
+	// @Accept  json
+	// @Produce  json
+	// @Router /middleware [get]
+	List() ([]string, error)
+
+	// @Summary Delete
+	// @x-gogen-middleware audit,rateLimit
+	// @Param   id      path   int   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Router /middleware/{id} [delete]
+	Delete(id int) error
+}
+
+type LimitsSvc interface {
+	// @Summary Search
+	// @x-gogen-timeout 5s
+	// @x-gogen-max-items 1000
+	// @Param   ids      query   []int64   false  "ids"
+	// @Param   tags     query   []string  false  "tags" collectionFormat(multi)
+	// @Accept  json
+	// @Produce  json
+	// @Router /limits [get]
+	Search(ctx context.Context, ids []int64, tags []string) ([]string, error)
+
+	// @Summary Upload
+	// @x-gogen-timeout 1500ms
+	// @x-gogen-max-body 1MB
+	// @Param   typ      body   TypeInfo   true  "type"
+	// @Accept  json
+	// @Produce  json
+	// @Router /limits [post]
+	Upload(ctx context.Context, typ *TypeInfo) error
+}
+
 // @gogen.optional_route_prefix /optpre
 type OptionalPrefixSvc interface {
 	// @Summary Get
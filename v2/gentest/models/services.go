@@ -0,0 +1,13 @@
+package models
+
+// RequestReader 可以被其它包中的服务嵌入
+type RequestReader interface {
+	// @Summary GetRequest
+	// @ID GetRequest
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {object} Request	"ok"
+	// @Router /requests/{id} [get]
+	GetRequest(id int64) (*Request, error)
+}
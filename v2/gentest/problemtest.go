@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+)
+
+var ErrProblemNotFound = errors.New("not found")
+
+type ProblemSvc interface {
+	// @Summary Get
+	// @ID ProblemGet
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @x-gogen-error ErrProblemNotFound 404
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /problem/{id} [get]
+	Get(id int64) (*TypeInfo, error)
+
+	// @Summary Create
+	// @ID ProblemCreate
+	// @Param   item      body   TypeInfo   true  "item"
+	// @Accept  json
+	// @Produce  json
+	// @Success 201 {string} string	"ok"
+	// @Router /problem [post]
+	Create(item *TypeInfo) error
+
+	// @Summary Find
+	// @ID ProblemFind
+	// @Tags problem,search
+	// @Param   name      query   string   false  "name"
+	// @Param   limit     query   int      false  "limit"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {array} TypeInfo	"ok"
+	// @Router /problem [get]
+	Find(name string, limit int) ([]TypeInfo, error)
+
+	// @Summary Owner
+	// @ID ProblemOwner
+	// @x-gogen-context tenantID TenantKey
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  json
+	// @Success 200 {string} string	"ok"
+	// @Router /problem/{id}/owner [get]
+	Owner(tenantID string, id int64) (string, error)
+}
@@ -0,0 +1,30 @@
+//go:build go1.23
+// +build go1.23
+
+package main
+
+import (
+	"context"
+	"iter"
+)
+
+// iter.Seq2 需要 go1.23, 所以这些方法单独放在这个文件中
+type SeqSvc interface {
+	// @Summary TestWatchSeq
+	// @ID TestWatchSeq
+	// @Param   id      path   int64   true  "id"
+	// @Accept  json
+	// @Produce  text/event-stream
+	// @Success 200 {object} TypeInfo	"ok"
+	// @Router /watch_seq/{id} [get]
+	TestWatchSeq(ctx context.Context, id int64) (iter.Seq2[TypeInfo, error], error)
+
+	// @Summary TestListSeq
+	// @ID TestListSeq
+	// @Param   name      query   string   false  "name"
+	// @Accept  json
+	// @Produce  application/x-ndjson
+	// @Success 200 {array} TypeInfo	"ok"
+	// @Router /list_seq [get]
+	TestListSeq(ctx context.Context, name string) (iter.Seq2[TypeInfo, error], error)
+}